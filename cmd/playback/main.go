@@ -1,16 +1,22 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/streamhive/playback-service/internal/config"
 	"github.com/streamhive/playback-service/internal/db"
+	"github.com/streamhive/playback-service/internal/metrics"
 	"github.com/streamhive/playback-service/internal/playback"
+	"github.com/streamhive/playback-service/internal/tracing"
 )
 
 func main() {
@@ -18,24 +24,65 @@ func main() {
 	if err != nil {
 		log.Fatalf("logger: %v", err)
 	}
+	// Runs last, after the other deferred cleanup: os.Exit skips defers.
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
 	defer logger.Sync()
 	logr := logger.Sugar()
 
-	database, err := db.NewConnection()
+	cfg, err := config.Load()
+	if err != nil {
+		logr.Fatalf("config: %v", err)
+	}
+	cfg.Log(logr)
+
+	shutdownTracing, err := tracing.Init(context.Background(), logr)
+	if err != nil {
+		logr.Fatalf("tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logr.Warnw("tracing shutdown", "err", err)
+		}
+	}()
+
+	database, closeDB, err := db.NewConnection(cfg.Database, logr)
 	if err != nil {
 		logr.Fatalf("db: %v", err)
 	}
 
-	h := playback.NewHandler(database, logr)
+	if err := metrics.InstrumentDB(database); err != nil {
+		logr.Warnw("db metrics not registered", "err", err)
+	}
+	if err := tracing.InstrumentDB(database); err != nil {
+		logr.Warnw("db tracing not registered", "err", err)
+	}
+	h, err := playback.NewHandler(database, logr, cfg)
+	if err != nil {
+		closeDB()
+		logr.Fatalf("handler: %v", err)
+	}
 
 	r := gin.New()
-	r.Use(gin.Logger(), gin.Recovery())
+	// ClientIP (used for IP-bound playback tokens) only believes
+	// X-Forwarded-For from these.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logr.Fatalf("trusted proxies: %v", err)
+	}
+	r.Use(gin.Logger(), gin.Recovery(), tracing.Middleware(), metrics.Middleware())
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Methods", "GET, HEAD, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Range, traceparent, tracestate")
+		c.Header("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -44,25 +91,76 @@ func main() {
 
 		c.Next()
 	})
+	r.Use(h.Authenticate())
 
-	r.GET("/health", h.Ready)
+	r.GET("/health", h.Livez)
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
 	r.GET("/playback/videos/:uploadId", h.GetDescriptor)
 	r.GET("/playback/videos/:uploadId/master.m3u8", h.GetMaster)
-	r.GET("/playback/videos/:uploadId/:rendition/index.m3u8", h.GetVariant)
-	r.GET("/playback/videos/:uploadId/:rendition/:segment", h.GetSegment)
+	r.GET("/playback/videos/:uploadId/:rendition/*file", h.GetRenditionFile)
 	r.GET("/playback/videos/:uploadId/thumbnail.jpg", h.GetThumbnail)
+	r.GET("/playback/videos/:uploadId/keys/:keyId", h.GetKey)
+	r.GET("/playback/videos/:uploadId/manifest.mpd", h.GetDASH)
+	r.HEAD("/playback/videos/:uploadId/master.m3u8", h.GetMaster)
+	r.HEAD("/playback/videos/:uploadId/manifest.mpd", h.GetDASH)
+	r.HEAD("/playback/videos/:uploadId/:rendition/*file", h.GetRenditionFile)
+	r.HEAD("/playback/videos/:uploadId/thumbnail.jpg", h.GetThumbnail)
+	r.POST("/admin/cache/purge/:uploadId", h.PurgeCache)
+
+	srv := &http.Server{Addr: cfg.Server.Addr(), Handler: r, ReadHeaderTimeout: 10 * time.Second}
+
+	// Metrics are served on a separate port that is not exposed publicly.
+	mr := gin.New()
+	mr.Use(gin.Recovery())
+	mr.GET("/metrics", metrics.Handler())
+	metricsSrv := &http.Server{Addr: cfg.Server.MetricsAddr(), Handler: mr, ReadHeaderTimeout: 10 * time.Second}
 
-	port := getEnv("PORT", "8090")
-	srv := &http.Server{Addr: ":" + port, Handler: r, ReadHeaderTimeout: 10 * time.Second}
-	logr.Infow("playback service listening", "port", port)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		logr.Fatalf("listen: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 2)
+	go func() {
+		logr.Infow("playback service listening", "port", cfg.Server.Port)
+		errc <- srv.ListenAndServe()
+	}()
+	go func() {
+		logr.Infow("metrics listening", "port", cfg.Server.MetricsPort)
+		errc <- metricsSrv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil && err != http.ErrServerClosed {
+			logr.Errorw("listen", "err", err)
+			exitCode = 1
+		}
+		srv.Close()
+	case <-ctx.Done():
+		stop()
+		// Fail readiness first so the load balancer stops routing here, then
+		// give it time to notice before refusing new connections.
+		h.StartDrain()
+		drain := cfg.Server.ShutdownDrain
+		logr.Infow("shutting down, draining connections", "drain", drain)
+		time.Sleep(drain)
+
+		sctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		if err := srv.Shutdown(sctx); err != nil {
+			logr.Warnw("http shutdown incomplete, closing remaining connections", "err", err)
+			srv.Close()
+		}
+		cancel()
 	}
-}
 
-func getEnv(k, d string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+	// Metrics stay up through the drain so the shutdown can be observed.
+	metricsSrv.Close()
+
+	if err := h.Close(); err != nil {
+		logr.Warnw("cache close", "err", err)
+	}
+	if err := closeDB(); err != nil {
+		logr.Warnw("db close", "err", err)
 	}
-	return d
+	logr.Infow("playback service stopped")
 }
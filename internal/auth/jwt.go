@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the bearer token claims the playback service relies on; the
+// subject is the caller's user ID.
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// UserID returns the authenticated user.
+func (c *Claims) UserID() string { return c.Subject }
+
+// Verifier validates HS256 and/or RS256 signed bearer tokens.
+type Verifier struct {
+	secret []byte
+	key    *rsa.PublicKey
+	opts   []jwt.ParserOption
+}
+
+// NewVerifier builds a verifier from an HMAC secret and/or a PEM encoded RSA
+// public key. Issuer and audience are checked when non-empty. It returns
+// (nil, nil) when neither key is configured.
+func NewVerifier(secret, publicKeyPEM, issuer, audience string) (*Verifier, error) {
+	if secret == "" && publicKeyPEM == "" {
+		return nil, nil
+	}
+	v := &Verifier{}
+	var methods []string
+	if secret != "" {
+		v.secret = []byte(secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if publicKeyPEM != "" {
+		key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("jwt public key: %w", err)
+		}
+		v.key = key
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	v.opts = []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if issuer != "" {
+		v.opts = append(v.opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		v.opts = append(v.opts, jwt.WithAudience(audience))
+	}
+	return v, nil
+}
+
+// Verify parses and validates a raw token.
+func (v *Verifier) Verify(raw string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, v.keyFunc, v.opts...)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	return claims, nil
+}
+
+func (v *Verifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	switch t.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return v.key, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "hs256-test-secret"
+
+// testKeys is an RSA key pair with the public half PEM encoded, as the
+// verifier is configured.
+type testKeys struct {
+	private   *rsa.PrivateKey
+	publicPEM string
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return testKeys{private: key, publicPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))}
+}
+
+// claims returns valid claims for user-1; edit modifies them.
+func claims(edit func(*jwt.RegisteredClaims)) jwt.RegisteredClaims {
+	now := time.Now()
+	c := jwt.RegisteredClaims{
+		Subject:   "user-1",
+		Issuer:    "https://id.example",
+		Audience:  jwt.ClaimStrings{"playback"},
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+	if edit != nil {
+		edit(&c)
+	}
+	return c
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, c jwt.RegisteredClaims) string {
+	t.Helper()
+	raw, err := jwt.NewWithClaims(method, c).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return raw
+}
+
+func TestVerifier(t *testing.T) {
+	keys := newTestKeys(t)
+	hsOnly, err := NewVerifier(testSecret, "", "https://id.example", "playback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsOnly, err := NewVerifier("", keys.publicPEM, "https://id.example", "playback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	both, err := NewVerifier(testSecret, keys.publicPEM, "https://id.example", "playback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := func(c jwt.RegisteredClaims) string { return sign(t, jwt.SigningMethodHS256, []byte(testSecret), c) }
+	rs := func(c jwt.RegisteredClaims) string { return sign(t, jwt.SigningMethodRS256, keys.private, c) }
+	tamper := func(raw string) string {
+		i := strings.LastIndexByte(raw, '.')
+		return raw[:i+1] + flipFirst(raw[i+1:])
+	}
+	// An HS256 token keyed with the public key, as a verifier that handed
+	// the RSA key to the HMAC check would accept.
+	confused := sign(t, jwt.SigningMethodHS256, []byte(keys.publicPEM), claims(nil))
+	unsigned := sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(nil))
+
+	tests := []struct {
+		name string
+		v    *Verifier
+		raw  string
+		ok   bool
+	}{
+		{"hs256", hsOnly, hs(claims(nil)), true},
+		{"rs256", rsOnly, rs(claims(nil)), true},
+		{"hs256 with both keys", both, hs(claims(nil)), true},
+		{"rs256 with both keys", both, rs(claims(nil)), true},
+		{"expired", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) })), false},
+		{"not yet valid", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour)) })), false},
+		{"no expiry", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.ExpiresAt = nil })), false},
+		{"no subject", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.Subject = "" })), false},
+		{"tampered hs256 signature", hsOnly, tamper(hs(claims(nil))), false},
+		{"tampered rs256 signature", rsOnly, tamper(rs(claims(nil))), false},
+		{"other secret", hsOnly, sign(t, jwt.SigningMethodHS256, []byte("other"), claims(nil)), false},
+		{"hs256 keyed with the public key", rsOnly, confused, false},
+		{"hs256 keyed with the public key, both keys", both, confused, false},
+		{"rs256 without a public key", hsOnly, rs(claims(nil)), false},
+		{"alg none", both, unsigned, false},
+		{"wrong issuer", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.Issuer = "https://evil.example" })), false},
+		{"wrong audience", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.Audience = jwt.ClaimStrings{"billing"} })), false},
+		{"no audience", hsOnly, hs(claims(func(c *jwt.RegisteredClaims) { c.Audience = nil })), false},
+		{"malformed", hsOnly, "not.a.jwt", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.v.Verify(tt.raw)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if got.UserID() != "user-1" {
+					t.Errorf("UserID = %q", got.UserID())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Verify accepted the token: %+v", got)
+			}
+		})
+	}
+}
+
+func TestNewVerifier(t *testing.T) {
+	v, err := NewVerifier("", "", "", "")
+	if v != nil || err != nil {
+		t.Errorf("no keys: %v, %v; want nil, nil", v, err)
+	}
+	if _, err := NewVerifier("", "not a pem key", "", ""); err == nil {
+		t.Error("invalid public key accepted")
+	}
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const claimsKey = "auth.claims"
+
+// Middleware authenticates an optional bearer token. Requests without one
+// continue anonymously; requests with an invalid one are rejected. With a
+// nil verifier every request is anonymous.
+func Middleware(v *Verifier, log *zap.SugaredLogger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw, ok := bearerToken(c.GetHeader("Authorization"))
+		if !ok || v == nil {
+			c.Next()
+			return
+		}
+		claims, err := v.Verify(raw)
+		if err != nil {
+			log.Debugw("rejected bearer token", "err", err)
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// FromContext returns the caller's claims, or nil for anonymous requests.
+func FromContext(c *gin.Context) *Claims {
+	if v, ok := c.Get(claimsKey); ok {
+		if claims, ok := v.(*Claims); ok {
+			return claims
+		}
+	}
+	return nil
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
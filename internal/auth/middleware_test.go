@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, err := NewVerifier(testSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := sign(t, jwt.SigningMethodHS256, []byte(testSecret), claims(nil))
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		header   string
+		code     int
+		user     string
+	}{
+		{"anonymous", v, "", http.StatusOK, ""},
+		{"valid bearer", v, "Bearer " + valid, http.StatusOK, "user-1"},
+		{"lower-case scheme", v, "bearer " + valid, http.StatusOK, "user-1"},
+		{"malformed bearer", v, "Bearer not-a-token", http.StatusUnauthorized, ""},
+		{"tampered bearer", v, "Bearer " + valid + "x", http.StatusUnauthorized, ""},
+		{"empty bearer", v, "Bearer ", http.StatusOK, ""},
+		{"other scheme", v, "Basic dXNlcjpwYXNz", http.StatusOK, ""},
+		{"no verifier", nil, "Bearer " + valid, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(Middleware(tt.verifier, zap.NewNop().Sugar()))
+			r.GET("/", func(c *gin.Context) {
+				user := ""
+				if claims := FromContext(c); claims != nil {
+					user = claims.UserID()
+				}
+				c.String(http.StatusOK, user)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("status = %d, want %d", w.Code, tt.code)
+			}
+			if tt.code == http.StatusUnauthorized {
+				if got := w.Header().Get("WWW-Authenticate"); got != `Bearer error="invalid_token"` {
+					t.Errorf("WWW-Authenticate = %q", got)
+				}
+				return
+			}
+			if got := w.Body.String(); got != tt.user {
+				t.Errorf("user = %q, want %q", got, tt.user)
+			}
+		})
+	}
+}
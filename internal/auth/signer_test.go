@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewURLSignerWithoutKey(t *testing.T) {
+	if s := NewURLSigner("", time.Hour, false); s != nil {
+		t.Fatalf("NewURLSigner(\"\") = %v, want nil", s)
+	}
+}
+
+func TestIssueWindow(t *testing.T) {
+	const ttl = 40 * time.Minute
+	s := NewURLSigner("url-key", ttl, false)
+	raw1, tok1 := s.Issue("u1", "192.0.2.1", nil)
+	raw2, _ := s.Issue("u1", "192.0.2.1", nil)
+	if raw1 != raw2 {
+		// Only a window boundary between the two calls changes the token.
+		if raw3, _ := s.Issue("u1", "192.0.2.1", nil); raw3 != raw2 {
+			t.Errorf("tokens differ within a window: %q, %q", raw2, raw3)
+		}
+	}
+	step := int64(ttl/time.Second) / 4
+	if start := tok1.Expires - int64(ttl/time.Second); start%step != 0 {
+		t.Errorf("validity starts at %d, not on a %ds boundary", start, step)
+	}
+	left := time.Until(tok1.ExpiresAt())
+	if left > ttl || left < ttl*3/4-time.Second {
+		t.Errorf("token valid for %v, want between 3/4 TTL and TTL", left)
+	}
+	if tok1.ClientIP != "" {
+		t.Errorf("ClientIP = %q without IP binding", tok1.ClientIP)
+	}
+	if _, tok := NewURLSigner("url-key", ttl, true).Issue("u1", "192.0.2.1", nil); tok.ClientIP != "192.0.2.1" {
+		t.Errorf("ClientIP = %q with IP binding", tok.ClientIP)
+	}
+	if _, tok := s.Issue("u1", "", []string{"360p", "720p"}); !tok.AllowsRendition("720p") || tok.AllowsRendition("1080p") {
+		t.Errorf("renditions not restricted: %+v", tok)
+	}
+}
+
+func TestVerifyURLToken(t *testing.T) {
+	s := NewURLSigner("url-key", time.Hour, true)
+	raw, _ := s.Issue("u1", "192.0.2.1", []string{"720p"})
+	payload, sig, _ := strings.Cut(raw, ".")
+
+	// A payload for another upload, carrying the original signature.
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"u":"u2","e":9999999999}`)) + "." + sig
+
+	tests := []struct {
+		name string
+		raw  string
+		ok   bool
+	}{
+		{"issued", raw, true},
+		{"tampered signature", payload + "." + flipFirst(sig), false},
+		{"tampered payload", forged, false},
+		{"other key", mustIssue(NewURLSigner("other-key", time.Hour, false), "u1"), false},
+		{"no signature", payload, false},
+		{"bad base64", payload + ".!!", false},
+		{"no upload", s.Sign(&PlaybackToken{Expires: time.Now().Add(time.Hour).Unix()}), false},
+		{"not json", base64.RawURLEncoding.EncodeToString([]byte("x")) + "." + base64.RawURLEncoding.EncodeToString(s.mac(base64.RawURLEncoding.EncodeToString([]byte("x")))), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := s.Verify(tt.raw)
+			if !tt.ok {
+				if !errors.Is(err, ErrTokenInvalid) {
+					t.Fatalf("Verify = %+v, %v; want ErrTokenInvalid", tok, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if tok.UploadID != "u1" || tok.ClientIP != "192.0.2.1" || len(tok.Renditions) != 1 {
+				t.Errorf("token = %+v", tok)
+			}
+		})
+	}
+}
+
+// flipFirst changes the first character of a base64url string.
+func flipFirst(s string) string {
+	if s[0] == 'A' {
+		return "B" + s[1:]
+	}
+	return "A" + s[1:]
+}
+
+func mustIssue(s *URLSigner, uploadID string) string {
+	raw, _ := s.Issue(uploadID, "", nil)
+	return raw
+}
+
+func TestTokenAllows(t *testing.T) {
+	future := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name      string
+		tok       PlaybackToken
+		uploadID  string
+		rendition string
+		ip        string
+		want      error
+	}{
+		{"valid", PlaybackToken{UploadID: "u1", Expires: future}, "u1", "720p", "192.0.2.1", nil},
+		{"expired", PlaybackToken{UploadID: "u1", Expires: time.Now().Add(-time.Second).Unix()}, "u1", "", "", ErrTokenExpired},
+		{"expires now", PlaybackToken{UploadID: "u1", Expires: time.Now().Unix()}, "u1", "", "", ErrTokenExpired},
+		{"other upload", PlaybackToken{UploadID: "u1", Expires: future}, "u2", "", "", ErrTokenScope},
+		{"bound ip", PlaybackToken{UploadID: "u1", Expires: future, ClientIP: "192.0.2.1"}, "u1", "", "192.0.2.1", nil},
+		{"other ip", PlaybackToken{UploadID: "u1", Expires: future, ClientIP: "192.0.2.1"}, "u1", "", "198.51.100.7", ErrTokenScope},
+		{"allowed rendition", PlaybackToken{UploadID: "u1", Expires: future, Renditions: []string{"360p"}}, "u1", "360p", "", nil},
+		{"other rendition", PlaybackToken{UploadID: "u1", Expires: future, Renditions: []string{"360p"}}, "u1", "1080p", "", ErrTokenScope},
+		{"not rendition specific", PlaybackToken{UploadID: "u1", Expires: future, Renditions: []string{"360p"}}, "u1", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tok.Allows(tt.uploadID, tt.rendition, tt.ip); !errors.Is(err, tt.want) || (err == nil) != (tt.want == nil) {
+				t.Errorf("Allows = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
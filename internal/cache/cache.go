@@ -1,90 +1,119 @@
+// Package cache provides the playback object cache. Redis is the shared
+// backend; an in-memory and a no-op implementation make it optional.
 package cache
 
 import (
 	"context"
 	"crypto/md5"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
-)
 
-type CacheService struct {
-	client *redis.Client
-	logger *zap.SugaredLogger
-	ttl    time.Duration
-}
+	"github.com/streamhive/playback-service/internal/config"
+)
 
-func NewCacheService(logger *zap.SugaredLogger) (*CacheService, error) {
-	host := getEnv("REDIS_HOST", "localhost")
-	port := getEnv("REDIS_PORT", "6379")
-	password := getEnv("REDIS_PASSWORD", "")
-	ttlSeconds, _ := strconv.Atoi(getEnv("CACHE_TTL", "3600"))
+// ErrUnavailable is returned by operations that must reach a shared backend
+// which is currently down, such as a purge.
+var ErrUnavailable = errors.New("cache backend unavailable")
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     fmt.Sprintf("%s:%s", host, port),
-		Password: password,
-		DB:       0, // default DB
-	})
+// Cache stores small playback objects (segments, thumbnails) by key,
+// together with their validators. Implementations report a miss as a nil
+// entry (or zero size) rather than an error. Returned data may be shared and
+// must not be modified.
+type Cache interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Set(ctx context.Context, key string, value []byte, meta Meta) error
+	// GetRange returns the bytes between start and end (inclusive, Redis
+	// GETRANGE semantics: negative offsets count from the end) together with
+	// the total length of the cached value. A zero length means a miss.
+	GetRange(ctx context.Context, key string, start, end int64) (*Entry, int64, error)
+	// Stat returns the length and validators of a cached value; the length
+	// is 0 on a miss.
+	Stat(ctx context.Context, key string) (int64, Meta, error)
+	// Purge drops every entry stored for an upload (Meta.UploadID) from all
+	// tiers reachable from this process and returns how many were removed.
+	// It fails with ErrUnavailable when a shared tier cannot be reached, so
+	// the caller can retry instead of leaving stale entries behind.
+	Purge(ctx context.Context, uploadID string) (int, error)
+	Stats() Stats
+	Close() error
+}
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
+// Meta holds the HTTP validators of a cached object and the upload it
+// belongs to, which is what purges are keyed on.
+type Meta struct {
+	UploadID     string    `json:"uploadId,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"lastModified,omitempty"`
+}
 
-	logger.Infow("Connected to Redis cache", "host", host, "port", port, "ttl", ttlSeconds)
+// Broadcaster is implemented by caches that can tell other pods to purge an
+// upload, so their in-process tiers do not keep serving stale bytes.
+type Broadcaster interface {
+	PublishPurge(ctx context.Context, uploadID string) error
+	// SubscribePurges calls fn for every purge request published by other
+	// pods or services until ctx is done; this pod's own are skipped.
+	SubscribePurges(ctx context.Context, fn func(uploadID string))
+}
 
-	return &CacheService{
-		client: client,
-		logger: logger,
-		ttl:    time.Duration(ttlSeconds) * time.Second,
-	}, nil
+// Entry is a cached value (or a range of it) with its validators.
+type Entry struct {
+	Data []byte
+	Meta Meta
 }
 
-func (c *CacheService) Get(ctx context.Context, key string) ([]byte, error) {
-	data, err := c.client.Get(ctx, key).Bytes()
-	if err == redis.Nil {
-		return nil, nil // Cache miss
-	}
-	if err != nil {
-		c.logger.Errorw("Cache get error", "key", key, "error", err)
-		return nil, err
-	}
-	
-	c.logger.Debugw("Cache hit", "key", key, "size", len(data))
-	return data, nil
+// Stats holds hit/miss counters per cache tier.
+type Stats struct {
+	LocalHits, LocalMisses int64
+	RedisHits, RedisMisses int64
+	LocalBytes             int64
 }
 
-func (c *CacheService) Set(ctx context.Context, key string, value []byte) error {
-	err := c.client.Set(ctx, key, value, c.ttl).Err()
-	if err != nil {
-		c.logger.Errorw("Cache set error", "key", key, "error", err)
-		return err
+// New builds the cache selected by cfg.Backend (redis|memory|none). A Redis
+// cache is returned even when Redis is down at boot; it reconnects in the
+// background.
+func New(cfg config.Cache, logger *zap.SugaredLogger) Cache {
+	switch cfg.Backend {
+	case "redis":
+		return NewRedisCache(cfg, logger)
+	case "memory":
+		logger.Infow("Using in-memory cache", "maxBytes", cfg.LocalMaxBytes)
+		return NewMemoryCache(cfg.LocalMaxBytes, cfg.LocalMaxEntryBytes, int(cfg.TTL/time.Second))
+	case "none":
+		logger.Infow("Caching disabled")
+		return Noop{}
+	default:
+		logger.Errorw("unknown cache backend; caching disabled", "backend", cfg.Backend)
+		return Noop{}
 	}
-	
-	c.logger.Debugw("Cache set", "key", key, "size", len(value), "ttl", c.ttl)
-	return nil
 }
 
-func (c *CacheService) GenerateKey(prefix, uploadID, path string) string {
+// GenerateKey builds a fixed-length cache key for an object.
+func GenerateKey(prefix, uploadID, path string) string {
 	// Create a hash-based key to avoid key length issues
 	hash := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", prefix, uploadID, path)))
 	return fmt.Sprintf("%s:%x", prefix, hash)
 }
 
-func (c *CacheService) Close() error {
-	return c.client.Close()
+// startSpan starts a span for a cache operation; spans are no-ops unless a
+// tracer provider is installed.
+func startSpan(ctx context.Context, op, key string) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer("github.com/streamhive/playback-service/internal/cache").Start(ctx, op)
+	if key != "" {
+		span.SetAttributes(attribute.String("cache.key", key))
+	}
+	return ctx, span
+}
+
+func hit(span trace.Span, tier string) {
+	span.SetAttributes(attribute.Bool("cache.hit", true), attribute.String("cache.tier", tier))
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+func miss(span trace.Span) {
+	span.SetAttributes(attribute.Bool("cache.hit", false))
 }
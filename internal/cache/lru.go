@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru is a byte-bounded, in-process LRU used as the first cache tier.
+// Values are shared with callers and must be treated as read-only.
+type lru struct {
+	mu       sync.Mutex
+	maxBytes int64
+	maxEntry int64
+	ttl      time.Duration
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	value   *Entry
+	expires time.Time
+}
+
+func newLRU(maxBytes, maxEntry int64, ttl time.Duration) *lru {
+	if maxEntry <= 0 || maxEntry > maxBytes {
+		maxEntry = maxBytes
+	}
+	return &lru{
+		maxBytes: maxBytes,
+		maxEntry: maxEntry,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string) (*Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*lruEntry)
+	if l.ttl > 0 && time.Now().After(e.expires) {
+		l.remove(el)
+		return nil, false
+	}
+	l.order.MoveToFront(el)
+	return e.value, true
+}
+
+// set stores value unless it exceeds the per-entry limit, evicting the least
+// recently used entries to stay within the byte budget.
+func (l *lru) set(key string, value *Entry) {
+	n := int64(len(value.Data))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+	if n == 0 || n > l.maxEntry {
+		return
+	}
+	for l.size+n > l.maxBytes {
+		l.remove(l.order.Back())
+	}
+	e := &lruEntry{key: key, value: value, expires: time.Now().Add(l.ttl)}
+	l.items[key] = l.order.PushFront(e)
+	l.size += n
+}
+
+// purge drops all entries of an upload.
+func (l *lru) purge(uploadID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for el := l.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*lruEntry).value.Meta.UploadID == uploadID {
+			l.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+func (l *lru) remove(el *list.Element) {
+	e := el.Value.(*lruEntry)
+	l.order.Remove(el)
+	delete(l.items, e.key)
+	l.size -= int64(len(e.value.Data))
+}
+
+// slice returns the entry restricted to a GETRANGE-style range.
+func (e *Entry) slice(start, end int64) *Entry {
+	return &Entry{Data: sliceRange(e.Data, start, end), Meta: e.Meta}
+}
+
+// sliceRange applies Redis GETRANGE semantics (inclusive, negative offsets
+// count from the end) to an in-memory value.
+func sliceRange(data []byte, start, end int64) []byte {
+	n := int64(len(data))
+	if start < 0 {
+		start = n + start
+	}
+	if end < 0 {
+		end = n + end
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= n {
+		end = n - 1
+	}
+	if start > end || start >= n {
+		return []byte{}
+	}
+	return data[start : end+1]
+}
+
+func (l *lru) bytes() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
@@ -0,0 +1,424 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+
+	"github.com/streamhive/playback-service/internal/config"
+)
+
+// Reconnect backoff while Redis is unreachable.
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// RedisCache is a two-tier cache: a size-bounded in-process LRU in front of
+// Redis. Redis hits are promoted into the LRU. While Redis is unreachable the
+// LRU keeps serving and a background loop pings until Redis is back.
+type RedisCache struct {
+	client *redis.Client
+	logger *zap.SugaredLogger
+	ttl    time.Duration
+	local  *lru
+	// purgeChannel carries upload IDs to purge between pods.
+	purgeChannel string
+	// instanceID tags this pod's purge messages so it can skip its own.
+	instanceID string
+
+	up           atomic.Bool
+	reconnecting atomic.Bool
+	stop         chan struct{}
+	closeOnce    sync.Once
+
+	localHits, localMisses atomic.Int64
+	redisHits, redisMisses atomic.Int64
+}
+
+// NewRedisCache connects to Redis; the in-process tier is disabled when
+// cfg.LocalMaxBytes is 0.
+func NewRedisCache(cfg config.Cache, logger *zap.SugaredLogger) *RedisCache {
+	host, port := cfg.RedisHost, cfg.RedisPort
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     fmt.Sprintf("%s:%s", host, port),
+		Password: cfg.RedisPassword,
+		DB:       0, // default DB
+	})
+
+	c := &RedisCache{
+		client:       client,
+		logger:       logger,
+		ttl:          cfg.TTL,
+		purgeChannel: cfg.PurgeChannel,
+		instanceID:   newInstanceID(),
+		stop:         make(chan struct{}),
+	}
+	if cfg.LocalMaxBytes > 0 {
+		c.local = newLRU(cfg.LocalMaxBytes, cfg.LocalMaxEntryBytes, cfg.TTL)
+		logger.Infow("In-process cache enabled", "maxBytes", cfg.LocalMaxBytes, "maxEntryBytes", c.local.maxEntry)
+	}
+
+	// Test connection
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		logger.Errorw("Redis unavailable; retrying in background", "host", host, "port", port, "error", err)
+		c.markDown()
+		return c
+	}
+	c.up.Store(true)
+
+	logger.Infow("Connected to Redis cache", "host", host, "port", port, "ttl", cfg.TTL)
+	return c
+}
+
+// Available reports whether Redis answered the last command or ping.
+func (c *RedisCache) Available() bool {
+	return c.up.Load()
+}
+
+// Ping checks Redis directly, regardless of the cached availability state.
+func (c *RedisCache) Ping(ctx context.Context) error {
+	err := c.client.Ping(ctx).Err()
+	if err != nil {
+		c.failed(err)
+	}
+	return err
+}
+
+// markDown stops sending commands to Redis and starts the reconnect loop
+// unless it is already running.
+func (c *RedisCache) markDown() {
+	c.up.Store(false)
+	if !c.reconnecting.CompareAndSwap(false, true) {
+		return
+	}
+	go c.reconnect()
+}
+
+func (c *RedisCache) reconnect() {
+	defer c.reconnecting.Store(false)
+	delay := minReconnectDelay
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-time.After(delay):
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := c.client.Ping(ctx).Err()
+		cancel()
+		if err == nil {
+			c.up.Store(true)
+			c.logger.Infow("Reconnected to Redis cache")
+			return
+		}
+		c.logger.Debugw("Redis still unavailable", "error", err, "retryIn", delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// failed records a command error; connection-level failures trigger the
+// reconnect loop. Caller cancellation and deadlines are not Redis' fault;
+// the client's own read and write timeouts surface as network errors.
+func (c *RedisCache) failed(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		return // server replied with an error; the connection is fine
+	}
+	c.markDown()
+}
+
+// getLocal looks a key up in the in-process tier and counts the outcome.
+func (c *RedisCache) getLocal(key string) (*Entry, bool) {
+	if c.local == nil {
+		return nil, false
+	}
+	e, ok := c.local.get(key)
+	if ok {
+		c.localHits.Add(1)
+	} else {
+		c.localMisses.Add(1)
+	}
+	return e, ok
+}
+
+// Stats returns a snapshot of the per-tier counters.
+func (c *RedisCache) Stats() Stats {
+	s := Stats{
+		LocalHits:   c.localHits.Load(),
+		LocalMisses: c.localMisses.Load(),
+		RedisHits:   c.redisHits.Load(),
+		RedisMisses: c.redisMisses.Load(),
+	}
+	if c.local != nil {
+		s.LocalBytes = c.local.bytes()
+	}
+	return s
+}
+
+// Validators live next to the value so GETRANGE keeps working on raw bytes.
+func metaKey(key string) string { return key + ":meta" }
+
+// indexKey names the set of cache keys stored for an upload.
+func indexKey(uploadID string) string { return "upload-keys:" + uploadID }
+
+func decodeMeta(cmd *redis.StringCmd) Meta {
+	var m Meta
+	if raw, err := cmd.Bytes(); err == nil {
+		_ = json.Unmarshal(raw, &m)
+	}
+	return m
+}
+
+// Get returns a cached entry, or nil on a miss.
+func (c *RedisCache) Get(ctx context.Context, key string) (*Entry, error) {
+	ctx, span := startSpan(ctx, "cache.get", key)
+	defer span.End()
+	if e, ok := c.getLocal(key); ok {
+		hit(span, "local")
+		return e, nil
+	}
+	if !c.up.Load() {
+		miss(span)
+		return nil, nil
+	}
+	pipe := c.client.Pipeline()
+	dataCmd := pipe.Get(ctx, key)
+	metaCmd := pipe.Get(ctx, metaKey(key))
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		c.failed(err)
+		c.logger.Errorw("Cache get error", "key", key, "error", err)
+		return nil, err
+	}
+	data, err := dataCmd.Bytes()
+	if err == redis.Nil {
+		c.redisMisses.Add(1)
+		miss(span)
+		return nil, nil // Cache miss
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.redisHits.Add(1)
+	hit(span, "redis")
+	e := &Entry{Data: data, Meta: decodeMeta(metaCmd)}
+	if c.local != nil {
+		c.local.set(key, e)
+	}
+
+	c.logger.Debugw("Cache hit", "key", key, "size", len(data))
+	return e, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, meta Meta) error {
+	ctx, span := startSpan(ctx, "cache.set", key)
+	defer span.End()
+	if c.local != nil {
+		c.local.set(key, &Entry{Data: value, Meta: meta})
+	}
+	if !c.up.Load() {
+		return nil
+	}
+	rawMeta, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, key, value, c.ttl)
+	pipe.Set(ctx, metaKey(key), rawMeta, c.ttl)
+	if meta.UploadID != "" {
+		idx := indexKey(meta.UploadID)
+		pipe.SAdd(ctx, idx, key)
+		// The index outlives nothing it points at by more than one TTL.
+		pipe.Expire(ctx, idx, c.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		c.failed(err)
+		span.RecordError(err)
+		c.logger.Errorw("Cache set error", "key", key, "error", err)
+		return err
+	}
+
+	c.logger.Debugw("Cache set", "key", key, "size", len(value), "ttl", c.ttl)
+	return nil
+}
+
+func (c *RedisCache) GetRange(ctx context.Context, key string, start, end int64) (*Entry, int64, error) {
+	ctx, span := startSpan(ctx, "cache.getrange", key)
+	defer span.End()
+	if e, ok := c.getLocal(key); ok {
+		hit(span, "local")
+		return e.slice(start, end), int64(len(e.Data)), nil
+	}
+	if !c.up.Load() {
+		miss(span)
+		return nil, 0, nil
+	}
+	pipe := c.client.Pipeline()
+	lenCmd := pipe.StrLen(ctx, key)
+	rangeCmd := pipe.GetRange(ctx, key, start, end)
+	metaCmd := pipe.Get(ctx, metaKey(key))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		c.failed(err)
+		c.logger.Errorw("Cache get range error", "key", key, "error", err)
+		return nil, 0, err
+	}
+	size := lenCmd.Val()
+	if size == 0 {
+		c.redisMisses.Add(1)
+		miss(span)
+		return nil, 0, nil // Cache miss
+	}
+	c.redisHits.Add(1)
+	hit(span, "redis")
+	data := []byte(rangeCmd.Val())
+	c.logger.Debugw("Cache range hit", "key", key, "size", size, "len", len(data))
+	return &Entry{Data: data, Meta: decodeMeta(metaCmd)}, size, nil
+}
+
+func (c *RedisCache) Stat(ctx context.Context, key string) (int64, Meta, error) {
+	ctx, span := startSpan(ctx, "cache.stat", key)
+	defer span.End()
+	if e, ok := c.getLocal(key); ok {
+		hit(span, "local")
+		return int64(len(e.Data)), e.Meta, nil
+	}
+	if !c.up.Load() {
+		return 0, Meta{}, nil
+	}
+	pipe := c.client.Pipeline()
+	lenCmd := pipe.StrLen(ctx, key)
+	metaCmd := pipe.Get(ctx, metaKey(key))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		c.failed(err)
+		c.logger.Errorw("Cache strlen error", "key", key, "error", err)
+		return 0, Meta{}, err
+	}
+	return lenCmd.Val(), decodeMeta(metaCmd), nil
+}
+
+// Purge deletes an upload's entries from the local tier and, through the
+// per-upload key index, from Redis. While Redis is down only the local tier
+// is purged and ErrUnavailable is returned, since the Redis copies would be
+// served again once it is back.
+func (c *RedisCache) Purge(ctx context.Context, uploadID string) (int, error) {
+	ctx, span := startSpan(ctx, "cache.purge", "")
+	defer span.End()
+	span.SetAttributes(attribute.String("upload_id", uploadID))
+	n := 0
+	if c.local != nil {
+		n = c.local.purge(uploadID)
+	}
+	if !c.up.Load() {
+		return n, ErrUnavailable
+	}
+	idx := indexKey(uploadID)
+	keys, err := c.client.SMembers(ctx, idx).Result()
+	if err != nil {
+		c.failed(err)
+		return n, err
+	}
+	del := []string{idx}
+	for _, k := range keys {
+		del = append(del, k, metaKey(k))
+	}
+	if err := c.client.Del(ctx, del...).Err(); err != nil {
+		c.failed(err)
+		return n, err
+	}
+	c.logger.Infow("Cache purged", "uploadId", uploadID, "redisKeys", len(keys), "localKeys", n)
+	return n + len(keys), nil
+}
+
+// purgeMessage is a purge request on the purge channel. Origin is the
+// instance ID of the publishing pod; messages from other publishers (e.g.
+// the catalog service) may omit it or be a bare upload ID.
+type purgeMessage struct {
+	UploadID string `json:"uploadId"`
+	Origin   string `json:"origin,omitempty"`
+}
+
+// PublishPurge asks every other pod to purge an upload. The caller has
+// already purged this one.
+func (c *RedisCache) PublishPurge(ctx context.Context, uploadID string) error {
+	payload, err := json.Marshal(purgeMessage{UploadID: uploadID, Origin: c.instanceID})
+	if err != nil {
+		return err
+	}
+	return c.client.Publish(ctx, c.purgeChannel, payload).Err()
+}
+
+// SubscribePurges listens on the purge channel, skipping this pod's own
+// messages. Messages are either a bare upload ID or a JSON purgeMessage.
+// The subscription is re-established by the client after connection loss.
+func (c *RedisCache) SubscribePurges(ctx context.Context, fn func(uploadID string)) {
+	sub := c.client.Subscribe(ctx, c.purgeChannel)
+	defer sub.Close()
+	c.logger.Infow("Listening for cache purges", "channel", c.purgeChannel, "instance", c.instanceID)
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			m := parsePurge(msg.Payload)
+			switch {
+			case m.UploadID == "":
+				c.logger.Warnw("Ignoring malformed purge message", "payload", msg.Payload)
+			case m.Origin != c.instanceID:
+				fn(m.UploadID)
+			}
+		}
+	}
+}
+
+func parsePurge(payload string) purgeMessage {
+	payload = strings.TrimSpace(payload)
+	if !strings.HasPrefix(payload, "{") {
+		return purgeMessage{UploadID: payload}
+	}
+	var m purgeMessage
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		return purgeMessage{}
+	}
+	return m
+}
+
+// newInstanceID returns a random ID for this process.
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (c *RedisCache) Close() error {
+	c.closeOnce.Do(func() { close(c.stop) })
+	return c.client.Close()
+}
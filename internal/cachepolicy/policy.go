@@ -0,0 +1,190 @@
+// Package cachepolicy decides the HTTP caching headers for playback
+// responses from the object type, the video's visibility and whether the
+// content is VOD or live. Rules come from built-in defaults, optionally
+// overridden by a YAML file.
+package cachepolicy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the type of object being served.
+type Kind string
+
+const (
+	Master     Kind = "master"
+	Variant    Kind = "variant"
+	Segment    Kind = "segment"
+	Thumbnail  Kind = "thumbnail"
+	Manifest   Kind = "manifest"
+	Descriptor Kind = "descriptor"
+	Key        Kind = "key"
+)
+
+var kinds = []Kind{Master, Variant, Segment, Thumbnail, Manifest, Descriptor, Key}
+
+// Rule is one set of caching directives. Durations are in seconds.
+type Rule struct {
+	MaxAge               int  `yaml:"max_age"`
+	SharedMaxAge         *int `yaml:"s_maxage"`
+	CDNMaxAge            *int `yaml:"cdn_max_age"`
+	StaleWhileRevalidate int  `yaml:"stale_while_revalidate"`
+	StaleIfError         int  `yaml:"stale_if_error"`
+	Immutable            bool `yaml:"immutable"`
+	NoStore              bool `yaml:"no_store"`
+}
+
+// Object holds the rules for one kind. Live falls back to VOD and Private
+// (applied to private videos) falls back to the state's rule.
+type Object struct {
+	VOD     Rule  `yaml:"vod"`
+	Live    *Rule `yaml:"live"`
+	Private *Rule `yaml:"private"`
+}
+
+// Policy maps object kinds to their rules.
+type Policy struct {
+	objects map[Kind]Object
+}
+
+// Request describes the response being cached.
+type Request struct {
+	Kind Kind
+	// Private is set for private videos and for responses personalised
+	// with a playback token; they are never cacheable by shared caches.
+	Private bool
+	Live    bool
+}
+
+// Headers are the caching headers for one response; empty values are not set.
+type Headers struct {
+	CacheControl     string
+	SurrogateControl string
+	CDNCacheControl  string
+}
+
+func intp(n int) *int { return &n }
+
+// Default returns the built-in policy.
+func Default() *Policy {
+	return &Policy{objects: map[Kind]Object{
+		Master: {
+			VOD:  Rule{MaxAge: 60, StaleWhileRevalidate: 30},
+			Live: &Rule{MaxAge: 1},
+		},
+		Variant: {
+			VOD:  Rule{MaxAge: 300, StaleWhileRevalidate: 60},
+			Live: &Rule{MaxAge: 1},
+		},
+		Segment: {
+			VOD:  Rule{MaxAge: 31536000, Immutable: true},
+			Live: &Rule{MaxAge: 60},
+		},
+		Thumbnail:  {VOD: Rule{MaxAge: 3600, StaleWhileRevalidate: 600}},
+		Manifest:   {VOD: Rule{MaxAge: 60, StaleWhileRevalidate: 30}, Live: &Rule{MaxAge: 1}},
+		Descriptor: {VOD: Rule{MaxAge: 30, SharedMaxAge: intp(60)}},
+		Key:        {VOD: Rule{NoStore: true}},
+	}}
+}
+
+// Load reads a YAML file mapping kinds to rules on top of the defaults. A
+// kind present in the file replaces its default entirely, e.g.
+//
+//	segment:
+//	  vod: {max_age: 31536000, cdn_max_age: 604800, immutable: true}
+//	  live: {max_age: 4}
+//	  private: {max_age: 600}
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file map[Kind]Object
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("cache policy %s: %w", path, err)
+	}
+	p := Default()
+	for kind, obj := range file {
+		if _, ok := p.objects[kind]; !ok {
+			return nil, fmt.Errorf("cache policy %s: unknown object type %q (want one of %v)", path, kind, kinds)
+		}
+		p.objects[kind] = obj
+	}
+	return p, nil
+}
+
+// Decide returns the caching headers for req. Private responses always get
+// Cache-Control: private and are kept out of CDNs whatever the rules say.
+func (p *Policy) Decide(req Request) Headers {
+	obj := p.objects[req.Kind]
+	rule := obj.VOD
+	if req.Live && obj.Live != nil {
+		rule = *obj.Live
+	}
+	if req.Private && obj.Private != nil {
+		rule = *obj.Private
+	}
+
+	if rule.NoStore {
+		return Headers{CacheControl: "private, no-store", SurrogateControl: "no-store", CDNCacheControl: "no-store"}
+	}
+	if req.Private {
+		d := []string{"private", "max-age=" + strconv.Itoa(rule.MaxAge)}
+		if rule.Immutable {
+			d = append(d, "immutable")
+		}
+		return Headers{CacheControl: strings.Join(d, ", "), SurrogateControl: "no-store", CDNCacheControl: "no-store"}
+	}
+
+	d := []string{"public", "max-age=" + strconv.Itoa(rule.MaxAge)}
+	if rule.SharedMaxAge != nil {
+		d = append(d, "s-maxage="+strconv.Itoa(*rule.SharedMaxAge))
+	}
+	d = append(d, staleDirectives(rule)...)
+	if rule.Immutable {
+		d = append(d, "immutable")
+	}
+
+	cdnAge := rule.MaxAge
+	if rule.CDNMaxAge != nil {
+		cdnAge = *rule.CDNMaxAge
+	} else if rule.SharedMaxAge != nil {
+		cdnAge = *rule.SharedMaxAge
+	}
+	cdn := append([]string{"max-age=" + strconv.Itoa(cdnAge)}, staleDirectives(rule)...)
+	return Headers{
+		CacheControl:     strings.Join(d, ", "),
+		SurrogateControl: strings.Join(cdn, ", "),
+		CDNCacheControl:  strings.Join(cdn, ", "),
+	}
+}
+
+func staleDirectives(r Rule) []string {
+	var d []string
+	if r.StaleWhileRevalidate > 0 {
+		d = append(d, "stale-while-revalidate="+strconv.Itoa(r.StaleWhileRevalidate))
+	}
+	if r.StaleIfError > 0 {
+		d = append(d, "stale-if-error="+strconv.Itoa(r.StaleIfError))
+	}
+	return d
+}
+
+// Apply sets the non-empty headers on h.
+func (hd Headers) Apply(h http.Header) {
+	if hd.CacheControl != "" {
+		h.Set("Cache-Control", hd.CacheControl)
+	}
+	if hd.SurrogateControl != "" {
+		h.Set("Surrogate-Control", hd.SurrogateControl)
+	}
+	if hd.CDNCacheControl != "" {
+		h.Set("CDN-Cache-Control", hd.CDNCacheControl)
+	}
+}
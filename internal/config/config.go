@@ -0,0 +1,322 @@
+// Package config loads and validates the playback service settings.
+//
+// Every setting has a default and can be overridden, in increasing order of
+// precedence, by an optional YAML file (PLAYBACK_CONFIG_FILE), an environment
+// variable and, for secrets, a file under the secrets-store mount. Struct
+// tags declare the sources of each field:
+//
+//	yaml:"name"     key in the YAML file
+//	env:"NAME"      environment variable
+//	secret:"file"   file under SecretsDir, preferred over env when present
+//	default:"v"     value when nothing else is set
+//	unit:"ms|s"     unit of bare integers for durations in env and defaults
+//	redact:"true"   value is masked in the startup report (implied by secret
+//	                unless redact:"false")
+//
+// Durations in YAML are strings such as "1500ms" or "30s"; in env they are
+// integers in the field's unit or duration strings.
+//
+// Tracing is configured separately through the standard OTEL_* variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/streamhive/playback-service/internal/auth"
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+)
+
+// Config is the complete service configuration.
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Database Database `yaml:"database"`
+	Cache    Cache    `yaml:"cache"`
+	Blob     Blob     `yaml:"blob"`
+	Auth     Auth     `yaml:"auth"`
+	Playback Playback `yaml:"playback"`
+}
+
+// Server holds the HTTP listener, probe and shutdown settings.
+type Server struct {
+	Port string `yaml:"port" env:"PORT" default:"8090"`
+	// MetricsPort serves /metrics; keep it off the public ingress.
+	MetricsPort string `yaml:"metrics_port" env:"PLAYBACK_METRICS_PORT" default:"9090"`
+	// PublicURL is the external base URL (e.g. https://media.example.com)
+	// used for absolute URLs; empty derives it from each request.
+	PublicURL    string        `yaml:"public_url" env:"PLAYBACK_PUBLIC_URL"`
+	ReadyTimeout time.Duration `yaml:"ready_timeout" env:"PLAYBACK_READY_TIMEOUT_MS" unit:"ms" default:"2000"`
+	// TrustedProxies lists the addresses or CIDRs of the ingress and CDN
+	// whose X-Forwarded-* headers are believed; empty trusts none.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"PLAYBACK_TRUSTED_PROXIES"`
+	// ShutdownDrain is how long /readyz fails before the listener closes, so
+	// load balancers stop routing here first.
+	ShutdownDrain   time.Duration `yaml:"shutdown_drain" env:"PLAYBACK_SHUTDOWN_DRAIN_SECONDS" unit:"s" default:"5"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"PLAYBACK_SHUTDOWN_TIMEOUT_SECONDS" unit:"s" default:"20"`
+	AdminToken      string        `yaml:"admin_token" env:"PLAYBACK_ADMIN_TOKEN" secret:"playback-admin-token"`
+}
+
+// Database is the Postgres catalog connection.
+type Database struct {
+	Host     string `yaml:"host" env:"DB_HOST" default:"localhost"`
+	Port     string `yaml:"port" env:"DB_PORT" default:"5432"`
+	User     string `yaml:"user" env:"DB_USER" default:"postgres"`
+	Password string `yaml:"password" env:"DB_PASSWORD" default:"postgres" redact:"true"`
+	Name     string `yaml:"name" env:"DB_NAME" default:"video_catalog"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE" default:"disable"`
+	// Pool limits, applied to the primary and the replica separately.
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" default:"20"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME_SECONDS" unit:"s" default:"1800"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME_SECONDS" unit:"s" default:"300"`
+	// QueryTimeout bounds each query; a shorter request deadline wins.
+	QueryTimeout time.Duration `yaml:"query_timeout" env:"DB_QUERY_TIMEOUT_MS" unit:"ms" default:"3000"`
+	// ConnectTimeout is how long boot keeps retrying an unreachable primary.
+	ConnectTimeout time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT_SECONDS" unit:"s" default:"60"`
+	// ReplicaDSN, when set, receives all reads while it passes health checks;
+	// the primary serves them otherwise.
+	ReplicaDSN           string        `yaml:"replica_dsn" env:"DB_REPLICA_DSN" redact:"true"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"DB_REPLICA_CHECK_INTERVAL_MS" unit:"ms" default:"5000"`
+}
+
+// Cache is the object cache: Redis with an in-process tier, memory only, or
+// none.
+type Cache struct {
+	Backend       string        `yaml:"backend" env:"CACHE_BACKEND" default:"redis"`
+	RedisHost     string        `yaml:"redis_host" env:"REDIS_HOST" default:"localhost"`
+	RedisPort     string        `yaml:"redis_port" env:"REDIS_PORT" default:"6379"`
+	RedisPassword string        `yaml:"redis_password" env:"REDIS_PASSWORD" redact:"true"`
+	TTL           time.Duration `yaml:"ttl" env:"CACHE_TTL" unit:"s" default:"3600"`
+	// LocalMaxBytes budgets the in-process tier; 0 disables it.
+	LocalMaxBytes      int64  `yaml:"local_max_bytes" env:"CACHE_LOCAL_MAX_BYTES" default:"268435456"`
+	LocalMaxEntryBytes int64  `yaml:"local_max_entry_bytes" env:"CACHE_LOCAL_MAX_ENTRY_BYTES" default:"8388608"`
+	PurgeChannel       string `yaml:"purge_channel" env:"CACHE_PURGE_CHANNEL" default:"playback:purge"`
+}
+
+// Blob is the media store and the download policy in front of it.
+type Blob struct {
+	Backend               string `yaml:"backend" env:"PLAYBACK_BLOB_BACKEND" default:"azure"`
+	LocalDir              string `yaml:"local_dir" env:"PLAYBACK_BLOB_LOCAL_DIR"`
+	AzureAccount          string `yaml:"azure_account" env:"AZURE_STORAGE_ACCOUNT" secret:"azure-storage-account" redact:"false"`
+	AzureContainer        string `yaml:"azure_container" env:"AZURE_BLOB_CONTAINER" secret:"azure-storage-raw-container" redact:"false"`
+	AzureConnectionString string `yaml:"azure_connection_string" env:"AZURE_STORAGE_CONNECTION_STRING" secret:"azure-storage-connection-string"`
+	AzureKey              string `yaml:"azure_key" env:"AZURE_STORAGE_KEY" secret:"azure-storage-key"`
+	// AttemptTimeout bounds one download attempt; Retries more follow.
+	AttemptTimeout time.Duration `yaml:"attempt_timeout" env:"PLAYBACK_AZURE_TIMEOUT_MS" unit:"ms" default:"3000"`
+	Retries        int           `yaml:"retries" env:"PLAYBACK_AZURE_RETRIES" default:"2"`
+	// FetchTimeout bounds a coalesced fetch shared by several requests.
+	FetchTimeout    time.Duration `yaml:"fetch_timeout" env:"PLAYBACK_FETCH_TIMEOUT_MS" unit:"ms" default:"30000"`
+	BreakerReset    time.Duration `yaml:"breaker_reset" env:"PLAYBACK_CB_RESET_MS" unit:"ms" default:"10000"`
+	BreakerFailures int           `yaml:"breaker_failures" env:"PLAYBACK_CB_CONSECUTIVE_FAILS" default:"5"`
+	// MaxCacheObject caps the size of objects teed into the cache.
+	MaxCacheObject int64 `yaml:"max_cache_object_bytes" env:"PLAYBACK_CACHE_MAX_OBJECT_BYTES" default:"8388608"`
+	// ProbeKey names a blob whose properties /readyz reads. Empty checks
+	// the container instead, which needs account key or connection string
+	// access (not anonymous or SAS).
+	ProbeKey string `yaml:"probe_key" env:"PLAYBACK_BLOB_PROBE_KEY"`
+}
+
+// Auth holds bearer token verification and signed URL settings.
+type Auth struct {
+	JWTSecret         string        `yaml:"jwt_secret" env:"PLAYBACK_JWT_SECRET" secret:"playback-jwt-secret"`
+	JWTPublicKey      string        `yaml:"jwt_public_key" env:"PLAYBACK_JWT_PUBLIC_KEY" secret:"playback-jwt-public-key"`
+	JWTIssuer         string        `yaml:"jwt_issuer" env:"PLAYBACK_JWT_ISSUER"`
+	JWTAudience       string        `yaml:"jwt_audience" env:"PLAYBACK_JWT_AUDIENCE"`
+	URLSigningKey     string        `yaml:"url_signing_key" env:"PLAYBACK_URL_SIGNING_KEY" secret:"playback-url-signing-key"`
+	URLTokenTTL       time.Duration `yaml:"url_token_ttl" env:"PLAYBACK_URL_TOKEN_TTL_SECONDS" unit:"s" default:"14400"`
+	URLTokenBindIP    bool          `yaml:"url_token_bind_ip" env:"PLAYBACK_URL_TOKEN_BIND_IP"`
+	RequireSignedURLs bool          `yaml:"require_signed_urls" env:"PLAYBACK_REQUIRE_SIGNED_URLS"`
+}
+
+// Playback holds the in-process caches, prefetching and cache headers.
+type Playback struct {
+	RenditionTTL time.Duration `yaml:"rendition_ttl" env:"PLAYBACK_RENDITION_TTL_SECONDS" unit:"s" default:"300"`
+	// VideoTTL and VideoNegativeTTL bound how long video rows and unknown
+	// upload IDs are cached.
+	VideoTTL         time.Duration `yaml:"video_ttl" env:"PLAYBACK_VIDEO_CACHE_TTL_MS" unit:"ms" default:"30000"`
+	VideoNegativeTTL time.Duration `yaml:"video_negative_ttl" env:"PLAYBACK_VIDEO_NEGATIVE_TTL_MS" unit:"ms" default:"5000"`
+	// PrefetchSegments is how many segments after a requested one are
+	// warmed; 0 disables prefetching.
+	PrefetchSegments int    `yaml:"prefetch_segments" env:"PLAYBACK_PREFETCH_SEGMENTS" default:"3"`
+	PrefetchWorkers  int    `yaml:"prefetch_workers" env:"PLAYBACK_PREFETCH_WORKERS" default:"4"`
+	PrefetchQueue    int    `yaml:"prefetch_queue" env:"PLAYBACK_PREFETCH_QUEUE" default:"256"`
+	CachePolicyFile  string `yaml:"cache_policy_file" env:"PLAYBACK_CACHE_POLICY_FILE"`
+}
+
+// Load builds the configuration from defaults, the optional YAML file named
+// by PLAYBACK_CONFIG_FILE, the environment and the secrets store, and
+// validates it. All problems are reported together.
+func Load() (*Config, error) {
+	c := &Config{}
+	if err := c.load(os.Getenv("PLAYBACK_CONFIG_FILE")); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the configuration for values the service cannot run with.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%s must be positive, got %s", name, d)
+	}
+
+	s := c.Server
+	check(validPort(s.Port), "server.port: invalid port %q", s.Port)
+	check(validPort(s.MetricsPort) && s.MetricsPort != s.Port,
+		"server.metrics_port: want a valid port other than server.port, got %q", s.MetricsPort)
+	if s.PublicURL != "" {
+		u, err := url.Parse(s.PublicURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.RawQuery == "",
+			"server.public_url: want an absolute http(s) URL without query, got %q", s.PublicURL)
+	}
+	for _, p := range s.TrustedProxies {
+		_, err := parsePrefix(p)
+		check(err == nil, "server.trusted_proxies: want an IP address or CIDR, got %q", p)
+	}
+	positive("server.ready_timeout", s.ReadyTimeout)
+	check(s.ShutdownDrain >= 0, "server.shutdown_drain must not be negative")
+	positive("server.shutdown_timeout", s.ShutdownTimeout)
+
+	d := c.Database
+	check(d.Host != "", "database.host is required")
+	check(validPort(d.Port), "database.port: invalid port %q", d.Port)
+	check(d.User != "", "database.user is required")
+	check(d.Name != "", "database.name is required")
+	check(oneOf(d.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		"database.sslmode: unknown mode %q", d.SSLMode)
+	check(d.MaxOpenConns > 0, "database.max_open_conns must be positive")
+	check(d.MaxIdleConns >= 0 && d.MaxIdleConns <= d.MaxOpenConns,
+		"database.max_idle_conns must be between 0 and max_open_conns")
+	check(d.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(d.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+	positive("database.query_timeout", d.QueryTimeout)
+	positive("database.connect_timeout", d.ConnectTimeout)
+	if d.ReplicaDSN != "" {
+		// The DSN holds credentials, which parse errors may echo.
+		if _, err := pgx.ParseConfig(d.ReplicaDSN); err != nil {
+			check(false, "database.replica_dsn is not a valid connection string")
+		}
+		positive("database.replica_check_interval", d.ReplicaCheckInterval)
+	}
+
+	ca := c.Cache
+	check(oneOf(ca.Backend, "redis", "memory", "none"), "cache.backend: want redis, memory or none, got %q", ca.Backend)
+	if ca.Backend == "redis" {
+		check(ca.RedisHost != "", "cache.redis_host is required for the redis backend")
+		check(validPort(ca.RedisPort), "cache.redis_port: invalid port %q", ca.RedisPort)
+		check(ca.PurgeChannel != "", "cache.purge_channel is required for the redis backend")
+	}
+	positive("cache.ttl", ca.TTL)
+	check(ca.LocalMaxBytes >= 0, "cache.local_max_bytes must not be negative")
+	check(ca.LocalMaxEntryBytes > 0, "cache.local_max_entry_bytes must be positive")
+
+	b := c.Blob
+	switch b.Backend {
+	case "azure":
+		check(b.AzureContainer != "", "blob.azure_container is required for the azure backend")
+		check(b.AzureConnectionString != "" || b.AzureAccount != "",
+			"blob: the azure backend needs azure_connection_string or azure_account")
+	case "local":
+		check(b.LocalDir != "", "blob.local_dir is required for the local backend")
+	case "memory":
+	default:
+		check(false, "blob.backend: want azure, local or memory, got %q", b.Backend)
+	}
+	positive("blob.attempt_timeout", b.AttemptTimeout)
+	check(b.Retries >= 0, "blob.retries must not be negative")
+	positive("blob.fetch_timeout", b.FetchTimeout)
+	positive("blob.breaker_reset", b.BreakerReset)
+	check(b.BreakerFailures > 0, "blob.breaker_failures must be positive")
+	check(b.MaxCacheObject >= 0, "blob.max_cache_object_bytes must not be negative")
+
+	a := c.Auth
+	if _, err := auth.NewVerifier(a.JWTSecret, a.JWTPublicKey, a.JWTIssuer, a.JWTAudience); err != nil {
+		check(false, "auth: %v", err)
+	}
+	positive("auth.url_token_ttl", a.URLTokenTTL)
+	check(!a.RequireSignedURLs || a.URLSigningKey != "",
+		"auth.require_signed_urls is set but no url_signing_key is configured")
+
+	p := c.Playback
+	positive("playback.rendition_ttl", p.RenditionTTL)
+	positive("playback.video_ttl", p.VideoTTL)
+	positive("playback.video_negative_ttl", p.VideoNegativeTTL)
+	check(p.PrefetchSegments >= 0, "playback.prefetch_segments must not be negative")
+	check(p.PrefetchWorkers > 0, "playback.prefetch_workers must be positive")
+	check(p.PrefetchQueue > 0, "playback.prefetch_queue must be positive")
+	if p.CachePolicyFile != "" {
+		if _, err := cachepolicy.Load(p.CachePolicyFile); err != nil {
+			check(false, "playback.cache_policy_file: %v", err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Addr returns the host:port the HTTP server listens on.
+func (s Server) Addr() string {
+	return net.JoinHostPort("", s.Port)
+}
+
+// MetricsAddr returns the host:port the metrics server listens on.
+func (s Server) MetricsAddr() string {
+	return net.JoinHostPort("", s.MetricsPort)
+}
+
+// Proxies returns TrustedProxies as prefixes, skipping invalid entries.
+func (s Server) Proxies() []netip.Prefix {
+	var out []netip.Prefix
+	for _, p := range s.TrustedProxies {
+		if prefix, err := parsePrefix(p); err == nil {
+			out = append(out, prefix)
+		}
+	}
+	return out
+}
+
+// parsePrefix accepts a CIDR or a single address.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func validPort(p string) bool {
+	n, err := strconv.Atoi(p)
+	return err == nil && n > 0 && n < 65536
+}
+
+func oneOf(v string, options ...string) bool {
+	for _, o := range options {
+		if v == o {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretsDir is where the CSI secrets-store driver mounts secret files
+// (env: PLAYBACK_SECRETS_DIR).
+var SecretsDir = "/mnt/secrets-store"
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	stringsType  = reflect.TypeOf([]string(nil))
+)
+
+// field is one leaf setting together with its sources.
+type field struct {
+	path  string // dotted YAML path, e.g. cache.redis_host
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+func (f field) redacted() bool {
+	if r, ok := f.tag.Lookup("redact"); ok {
+		return r == "true"
+	}
+	_, secret := f.tag.Lookup("secret")
+	return secret
+}
+
+// fields walks the settings of c in declaration order.
+func (c *Config) fields() []field {
+	var out []field
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			if sf.Type.Kind() == reflect.Struct && sf.Type != durationType {
+				walk(name, v.Field(i))
+				continue
+			}
+			out = append(out, field{path: name, value: v.Field(i), tag: sf.Tag})
+		}
+	}
+	walk("", reflect.ValueOf(c).Elem())
+	return out
+}
+
+// load fills c from defaults, the YAML file (if any), the environment and
+// the secrets store, in that order.
+func (c *Config) load(file string) error {
+	var errs []error
+	for _, f := range c.fields() {
+		if d, ok := f.tag.Lookup("default"); ok {
+			if err := set(f, d); err != nil {
+				// A bad default is a programming error.
+				panic(fmt.Sprintf("config: default for %s: %v", f.path, err))
+			}
+		}
+	}
+	if file != "" {
+		if err := c.loadFile(file); err != nil {
+			return err
+		}
+	}
+	dir := SecretsDir
+	if d := os.Getenv("PLAYBACK_SECRETS_DIR"); d != "" {
+		dir = d
+	}
+	for _, f := range c.fields() {
+		if env := f.tag.Get("env"); env != "" {
+			if v := os.Getenv(env); v != "" {
+				if err := set(f, v); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", env, err))
+				}
+			}
+		}
+		if name := f.tag.Get("secret"); name != "" {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			switch {
+			case err == nil:
+				f.value.SetString(strings.TrimSpace(string(data)))
+			case !errors.Is(err, os.ErrNotExist):
+				errs = append(errs, fmt.Errorf("secret %s: %w", name, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *Config) loadFile(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", file, err)
+	}
+	return nil
+}
+
+// set parses s into the field according to its type.
+func set(f field, s string) error {
+	v := f.value
+	switch {
+	case v.Type() == durationType:
+		d, err := parseDuration(s, f.tag.Get("unit"))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case v.Type() == stringsType:
+		// Comma-separated, e.g. "10.0.0.0/8, 192.168.1.4".
+		var list []string
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// parseDuration accepts a bare integer in unit (ms or s) or a Go duration
+// string.
+func parseDuration(s, unit string) (time.Duration, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch unit {
+		case "ms":
+			return time.Duration(n) * time.Millisecond, nil
+		case "s":
+			return time.Duration(n) * time.Second, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// Log writes the effective configuration with secrets masked: set secrets
+// show as "[redacted]", unset ones as "".
+func (c *Config) Log(log *zap.SugaredLogger) {
+	kv := make([]interface{}, 0, 2*len(c.fields()))
+	for _, f := range c.fields() {
+		var v interface{} = f.value.Interface()
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		if f.redacted() && !f.value.IsZero() {
+			v = "[redacted]"
+		}
+		kv = append(kv, f.path, v)
+	}
+	log.Infow("effective configuration", kv...)
+}
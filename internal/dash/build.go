@@ -0,0 +1,343 @@
+package dash
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/streamhive/playback-service/internal/hls"
+)
+
+// ErrUnsupported is returned for content that cannot be described as DASH:
+// live playlists, MPEG-TS segments or HLS-style (AES) encryption.
+var ErrUnsupported = errors.New("content is not DASH compatible")
+
+// timescale of all segment timelines: milliseconds, which is the precision
+// EXTINF durations are written with in practice.
+const timescale = 1000
+
+// defaultAudioBandwidth is announced for audio renditions of unknown bitrate.
+const defaultAudioBandwidth = 128000
+
+// Track is one HLS rendition to expose in the MPD.
+type Track struct {
+	// Name is the rendition name; it becomes the Representation id (made
+	// unique within the MPD) and, with a trailing slash, its BaseURL.
+	Name string
+	// Exactly one of Variant (video or muxed) and Media (alternate audio)
+	// is set.
+	Variant  *hls.Variant
+	Media    *hls.Media
+	Playlist *hls.MediaPlaylist
+	// Codecs overrides the codecs of an audio track, which EXT-X-MEDIA
+	// does not carry.
+	Codecs string
+	// Bandwidth of an audio track, likewise; when zero it is taken from
+	// EXT-X-BITRATE or defaults to defaultAudioBandwidth.
+	Bandwidth int64
+}
+
+// Options tune manifest generation.
+type Options struct {
+	// Query is appended to every segment and initialization URL, e.g. a
+	// signed playback token.
+	Query string
+}
+
+// Build creates a static (VOD) MPD from parsed HLS renditions. Audio-only
+// variants are grouped with the alternate audio renditions. Representation
+// ids are the rendition names, suffixed when a name repeats.
+func Build(tracks []Track, opts Options) (*MPD, error) {
+	period := Period{ID: "0", Start: "PT0S"}
+	var duration float64
+	var video *AdaptationSet
+	audio := map[string]*AdaptationSet{}
+	var audioOrder []string
+	addAudio := func(key, lang string, main bool, rep Representation) {
+		as, ok := audio[key]
+		if !ok {
+			as = &AdaptationSet{ContentType: "audio", MimeType: "audio/mp4", Lang: lang, SegmentAlignment: true, StartWithSAP: 1}
+			if main {
+				as.Roles = []Descriptor{{SchemeIDURI: "urn:mpeg:dash:role:2011", Value: "main"}}
+			}
+			audio[key] = as
+			audioOrder = append(audioOrder, key)
+		}
+		as.Representations = append(as.Representations, rep)
+	}
+	ids := map[string]bool{}
+	for _, t := range tracks {
+		if !t.Playlist.IsVOD() {
+			return nil, fmt.Errorf("%w: rendition %s is not VOD", ErrUnsupported, t.Name)
+		}
+		rep, err := representation(t, opts)
+		if err != nil {
+			return nil, err
+		}
+		rep.ID = uniqueID(ids, rep.ID)
+		duration = math.Max(duration, t.Playlist.Duration())
+		switch {
+		case t.Variant != nil && t.Variant.AudioOnly():
+			addAudio("variant", "", false, rep)
+		case t.Variant != nil:
+			if video == nil {
+				video = &AdaptationSet{ContentType: "video", MimeType: "video/mp4", SegmentAlignment: true, StartWithSAP: 1}
+			}
+			video.Representations = append(video.Representations, rep)
+		case t.Media != nil && t.Media.Type == "AUDIO":
+			addAudio("media|"+t.Media.Language+"|"+t.Media.Name, t.Media.Language, t.Media.Default, rep)
+		}
+	}
+	if video != nil {
+		period.AdaptationSets = append(period.AdaptationSets, *video)
+	}
+	for _, k := range audioOrder {
+		period.AdaptationSets = append(period.AdaptationSets, *audio[k])
+	}
+	if len(period.AdaptationSets) == 0 {
+		return nil, fmt.Errorf("%w: no audio or video renditions", ErrUnsupported)
+	}
+	for i := range period.AdaptationSets {
+		period.AdaptationSets[i].ID = i
+	}
+	return &MPD{
+		Profiles:                  "urn:mpeg:dash:profile:isoff-live:2011",
+		Type:                      "static",
+		MediaPresentationDuration: isoDuration(duration),
+		MinBufferTime:             "PT2S",
+		Periods:                   []Period{period},
+	}, nil
+}
+
+// uniqueID returns id, or the first of id-2, id-3, ... not yet in used, and
+// marks it used.
+func uniqueID(used map[string]bool, id string) string {
+	out := id
+	for n := 2; used[out]; n++ {
+		out = fmt.Sprintf("%s-%d", id, n)
+	}
+	used[out] = true
+	return out
+}
+
+func representation(t Track, opts Options) (Representation, error) {
+	p := t.Playlist
+	if len(p.Segments) == 0 {
+		return Representation{}, fmt.Errorf("%w: rendition %s has no segments", ErrUnsupported, t.Name)
+	}
+	init := p.Segments[0].Map
+	if init == nil {
+		return Representation{}, fmt.Errorf("%w: rendition %s is not fMP4/CMAF", ErrUnsupported, t.Name)
+	}
+	for i, s := range p.Segments {
+		if i > 0 && s.Map != nil && (s.Map.URI != init.URI || !sameRange(s.Map.ByteRange, init.ByteRange)) {
+			return Representation{}, fmt.Errorf("%w: rendition %s changes its init section", ErrUnsupported, t.Name)
+		}
+		for _, k := range s.Keys {
+			if k.Method != "NONE" {
+				return Representation{}, fmt.Errorf("%w: rendition %s is encrypted", ErrUnsupported, t.Name)
+			}
+		}
+	}
+
+	rep := Representation{ID: t.Name, BaseURL: t.Name + "/"}
+	if v := t.Variant; v != nil {
+		rep.Bandwidth = v.Bandwidth
+		rep.Width, rep.Height = v.Width, v.Height
+		rep.FrameRate = frameRate(v.FrameRate)
+		rep.Codecs = v.Codecs
+		if v.Audio != "" && !v.AudioOnly() {
+			// Audio comes from a separate rendition; keep only video codecs.
+			rep.Codecs = filterCodecs(v.Codecs, hls.IsVideoCodec)
+		}
+	} else {
+		rep.Bandwidth = t.Bandwidth
+		if rep.Bandwidth == 0 {
+			rep.Bandwidth = maxBitrate(p.Segments)
+		}
+		if rep.Bandwidth == 0 {
+			rep.Bandwidth = defaultAudioBandwidth
+		}
+		rep.Codecs = t.Codecs
+		if ch, _, _ := strings.Cut(t.Media.Channels, "/"); ch != "" {
+			rep.AudioChannelConfiguration = []Descriptor{{
+				SchemeIDURI: "urn:mpeg:dash:23003:3:audio_channel_configuration:2011",
+				Value:       ch,
+			}}
+		}
+	}
+
+	timeline := buildTimeline(p.Segments)
+	if tmpl, ok := numberTemplate(p.Segments); ok && init.ByteRange == nil {
+		rep.SegmentTemplate = &SegmentTemplate{
+			Timescale:       timescale,
+			Initialization:  withQuery(hls.SegmentPath(init.URI), opts.Query),
+			Media:           withQuery(tmpl.media, opts.Query),
+			StartNumber:     tmpl.start,
+			SegmentTimeline: timeline,
+		}
+		return rep, nil
+	}
+
+	list := &SegmentList{
+		Timescale:       timescale,
+		Initialization:  &URLType{SourceURL: withQuery(hls.SegmentPath(init.URI), opts.Query)},
+		SegmentTimeline: timeline,
+	}
+	if br := init.ByteRange; br != nil {
+		list.Initialization.Range = fmt.Sprintf("%d-%d", br.Offset, br.Offset+br.Length-1)
+	}
+	var next int64
+	prevURI := ""
+	for _, s := range p.Segments {
+		u := SegmentURL{Media: withQuery(hls.SegmentPath(s.URI), opts.Query)}
+		if br := s.ByteRange; br != nil {
+			start := br.Offset
+			if !br.HasOffset {
+				if s.URI != prevURI {
+					return Representation{}, fmt.Errorf("%w: rendition %s has a byte range without offset", ErrUnsupported, t.Name)
+				}
+				start = next
+			}
+			u.MediaRange = fmt.Sprintf("%d-%d", start, start+br.Length-1)
+			next = start + br.Length
+		}
+		prevURI = s.URI
+		list.SegmentURLs = append(list.SegmentURLs, u)
+	}
+	rep.SegmentList = list
+	return rep, nil
+}
+
+// buildTimeline run-length encodes segment durations.
+func buildTimeline(segs []*hls.Segment) *SegmentTimeline {
+	tl := &SegmentTimeline{}
+	zero := int64(0)
+	for i, s := range segs {
+		d := int64(math.Round(s.Duration * timescale))
+		if n := len(tl.S); n > 0 && tl.S[n-1].D == d {
+			tl.S[n-1].R++
+			continue
+		}
+		e := S{D: d}
+		if i == 0 {
+			e.T = &zero
+		}
+		tl.S = append(tl.S, e)
+	}
+	return tl
+}
+
+var numbered = regexp.MustCompile(`^(.*?)(\d+)(\.[A-Za-z0-9]+)$`)
+
+type template struct {
+	media string
+	start int64
+}
+
+// numberTemplate detects segments named prefix<N>suffix with consecutive
+// numbers and derives a $Number$ template for them. Segment paths are taken
+// relative to the playlist (see hls.SegmentPath), so the prefix includes any
+// subdirectory.
+func numberTemplate(segs []*hls.Segment) (template, bool) {
+	var prefix, suffix string
+	var start int64
+	width := -1
+	padded := false
+	for i, s := range segs {
+		if s.ByteRange != nil {
+			return template{}, false
+		}
+		m := numbered.FindStringSubmatch(hls.SegmentPath(s.URI))
+		if m == nil {
+			return template{}, false
+		}
+		n, err := strconv.ParseInt(m[2], 10, 64)
+		if err != nil {
+			return template{}, false
+		}
+		if i == 0 {
+			prefix, suffix, start = m[1], m[3], n
+		} else if m[1] != prefix || m[3] != suffix || n != start+int64(i) {
+			return template{}, false
+		}
+		if len(m[2]) > 1 && m[2][0] == '0' {
+			padded = true
+		}
+		switch {
+		case width == -1:
+			width = len(m[2])
+		case width != len(m[2]):
+			width = 0 // varying widths: only valid unpadded
+		}
+	}
+	number := "$Number$"
+	if padded {
+		if width <= 0 {
+			return template{}, false
+		}
+		number = fmt.Sprintf("$Number%%0%dd$", width)
+	}
+	return template{media: prefix + number + suffix, start: start}, true
+}
+
+// maxBitrate returns the highest EXT-X-BITRATE (kbps) in bits per second.
+func maxBitrate(segs []*hls.Segment) int64 {
+	var max int64
+	for _, s := range segs {
+		if s.Bitrate*1000 > max {
+			max = s.Bitrate * 1000
+		}
+	}
+	return max
+}
+
+func sameRange(a, b *hls.ByteRange) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// frameRate formats an HLS FRAME-RATE as a DASH FrameRateType, using exact
+// NTSC fractions where they apply.
+func frameRate(f float64) string {
+	if f <= 0 {
+		return ""
+	}
+	if f == math.Trunc(f) {
+		return strconv.Itoa(int(f))
+	}
+	for _, base := range []int{24, 30, 48, 60, 120} {
+		if math.Abs(f-float64(base)*1000/1001) < 0.01 {
+			return fmt.Sprintf("%d/1001", base*1000)
+		}
+	}
+	return fmt.Sprintf("%d/1000", int(math.Round(f*1000)))
+}
+
+// AudioCodecs returns the audio codecs of an HLS CODECS attribute; the
+// playback layer uses it to label alternate audio renditions.
+func AudioCodecs(codecs string) string {
+	return filterCodecs(codecs, func(c string) bool { return !hls.IsVideoCodec(c) })
+}
+
+func filterCodecs(codecs string, keep func(string) bool) string {
+	var out []string
+	for _, c := range strings.Split(codecs, ",") {
+		if c = strings.TrimSpace(c); c != "" && keep(c) {
+			out = append(out, c)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+func withQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	return uri + "?" + query
+}
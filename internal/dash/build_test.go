@@ -0,0 +1,71 @@
+package dash
+
+import (
+	"testing"
+
+	"github.com/streamhive/playback-service/internal/hls"
+)
+
+func mediaPlaylist(t *testing.T, segments ...string) *hls.MediaPlaylist {
+	t.Helper()
+	data := "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:4\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-MAP:URI=\"chunks/init.mp4\"\n"
+	for _, s := range segments {
+		data += "#EXTINF:4.000,\n" + s + "\n"
+	}
+	p, err := hls.DecodeMedia([]byte(data + "#EXT-X-ENDLIST\n"))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return p
+}
+
+func TestBuildSegmentSubdirectories(t *testing.T) {
+	variant := &hls.Variant{Bandwidth: 2000000, Codecs: "avc1.64001f,mp4a.40.2"}
+	tests := []struct {
+		name     string
+		segments []string
+		template string   // SegmentTemplate media, if one is expected
+		list     []string // SegmentList media otherwise
+	}{
+		{"template", []string{"chunks/seg_001.m4s", "chunks/seg_002.m4s"}, "chunks/seg_$Number%03d$.m4s?token=t", nil},
+		{"list", []string{"chunks/a.m4s", "../720p/chunks/b.m4s", "https://cdn.example/v/c.m4s?x=1"}, "", []string{"chunks/a.m4s?token=t", "b.m4s?token=t", "c.m4s?token=t"}},
+		{"mixed directories", []string{"a/seg1.m4s", "b/seg2.m4s"}, "", []string{"a/seg1.m4s?token=t", "b/seg2.m4s?token=t"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mpd, err := Build([]Track{{Name: "720p", Variant: variant, Playlist: mediaPlaylist(t, tt.segments...)}}, Options{Query: "token=t"})
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			rep := mpd.Periods[0].AdaptationSets[0].Representations[0]
+			if rep.BaseURL != "720p/" {
+				t.Errorf("BaseURL = %q", rep.BaseURL)
+			}
+			if tt.template != "" {
+				st := rep.SegmentTemplate
+				if st == nil {
+					t.Fatal("no SegmentTemplate")
+				}
+				if st.Initialization != "chunks/init.mp4?token=t" || st.Media != tt.template || st.StartNumber != 1 {
+					t.Errorf("template = %q %q %d", st.Initialization, st.Media, st.StartNumber)
+				}
+				return
+			}
+			sl := rep.SegmentList
+			if sl == nil {
+				t.Fatal("no SegmentList")
+			}
+			if sl.Initialization.SourceURL != "chunks/init.mp4?token=t" {
+				t.Errorf("init = %q", sl.Initialization.SourceURL)
+			}
+			if len(sl.SegmentURLs) != len(tt.list) {
+				t.Fatalf("got %d segment URLs, want %d", len(sl.SegmentURLs), len(tt.list))
+			}
+			for i, want := range tt.list {
+				if got := sl.SegmentURLs[i].Media; got != want {
+					t.Errorf("segment %d = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+// Package dash builds MPEG-DASH manifests (MPDs) for CMAF content that is
+// packaged for HLS, so both protocols can share the same segments.
+package dash
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// MPD is the root element of a DASH manifest.
+type MPD struct {
+	XMLName                   xml.Name `xml:"urn:mpeg:dash:schema:mpd:2011 MPD"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Type                      string   `xml:"type,attr"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	Periods                   []Period `xml:"Period"`
+}
+
+// Period is a single presentation period.
+type Period struct {
+	ID             string          `xml:"id,attr"`
+	Start          string          `xml:"start,attr"`
+	AdaptationSets []AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet groups switchable representations of one content type.
+type AdaptationSet struct {
+	ID               int              `xml:"id,attr"`
+	ContentType      string           `xml:"contentType,attr"`
+	MimeType         string           `xml:"mimeType,attr"`
+	Lang             string           `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool             `xml:"segmentAlignment,attr"`
+	StartWithSAP     int              `xml:"startWithSAP,attr,omitempty"`
+	Roles            []Descriptor     `xml:"Role,omitempty"`
+	Representations  []Representation `xml:"Representation"`
+}
+
+// Descriptor is a generic schemeIdUri/value element such as Role.
+type Descriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+// Representation is one encoded version of the content.
+type Representation struct {
+	ID                        string           `xml:"id,attr"`
+	Bandwidth                 int64            `xml:"bandwidth,attr"`
+	Codecs                    string           `xml:"codecs,attr,omitempty"`
+	Width                     int              `xml:"width,attr,omitempty"`
+	Height                    int              `xml:"height,attr,omitempty"`
+	FrameRate                 string           `xml:"frameRate,attr,omitempty"`
+	AudioChannelConfiguration []Descriptor     `xml:"AudioChannelConfiguration,omitempty"`
+	BaseURL                   string           `xml:"BaseURL,omitempty"`
+	SegmentTemplate           *SegmentTemplate `xml:"SegmentTemplate,omitempty"`
+	SegmentList               *SegmentList     `xml:"SegmentList,omitempty"`
+}
+
+// SegmentTemplate addresses numbered segments with a SegmentTimeline.
+type SegmentTemplate struct {
+	Timescale       int64            `xml:"timescale,attr"`
+	Initialization  string           `xml:"initialization,attr"`
+	Media           string           `xml:"media,attr"`
+	StartNumber     int64            `xml:"startNumber,attr"`
+	SegmentTimeline *SegmentTimeline `xml:"SegmentTimeline"`
+}
+
+// SegmentTimeline lists segment durations, run-length encoded.
+type SegmentTimeline struct {
+	S []S `xml:"S"`
+}
+
+// S is one run of equally long segments.
+type S struct {
+	T *int64 `xml:"t,attr,omitempty"`
+	D int64  `xml:"d,attr"`
+	R int64  `xml:"r,attr,omitempty"`
+}
+
+// SegmentList addresses segments explicitly, optionally as byte ranges.
+type SegmentList struct {
+	Timescale       int64            `xml:"timescale,attr"`
+	Initialization  *URLType         `xml:"Initialization"`
+	SegmentTimeline *SegmentTimeline `xml:"SegmentTimeline"`
+	SegmentURLs     []SegmentURL     `xml:"SegmentURL"`
+}
+
+// URLType is an Initialization element.
+type URLType struct {
+	SourceURL string `xml:"sourceURL,attr"`
+	Range     string `xml:"range,attr,omitempty"`
+}
+
+// SegmentURL is one entry of a SegmentList.
+type SegmentURL struct {
+	Media      string `xml:"media,attr"`
+	MediaRange string `xml:"mediaRange,attr,omitempty"`
+}
+
+// Encode serialises the MPD with an XML declaration.
+func (m *MPD) Encode() ([]byte, error) {
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// isoDuration formats seconds as an ISO 8601 duration, e.g. PT634.567S.
+func isoDuration(seconds float64) string {
+	s := fmt.Sprintf("%.3f", seconds)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	return "PT" + s + "S"
+}
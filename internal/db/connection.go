@@ -1,28 +1,120 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"os"
+	"time"
 
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/streamhive/playback-service/internal/config"
+)
+
+// Backoff between connection attempts at boot.
+const (
+	minConnectDelay = 500 * time.Millisecond
+	maxConnectDelay = 10 * time.Second
 )
 
-func NewConnection() (*gorm.DB, error) {
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "postgres")
-	name := getEnv("DB_NAME", "video_catalog")
-	sslmode := getEnv("DB_SSLMODE", "disable")
+// NewConnection opens the catalog database, retrying with backoff until the
+// primary answers or cfg.ConnectTimeout passes. With a replica configured,
+// reads are routed to it while it is healthy. The returned func stops the
+// replica health checks and closes all pools.
+func NewConnection(cfg config.Database, log *zap.SugaredLogger) (*gorm.DB, func() error, error) {
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	db, err := connect(ctx, dsn, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	primary, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	tunePool(primary, cfg)
+	closers := []func() error{primary.Close}
+	closeAll := func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i]())
+		}
+		return errors.Join(errs...)
+	}
 
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", host, port, user, password, name, sslmode)
-	return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err := db.Use(queryTimeout(cfg.QueryTimeout)); err != nil {
+		closeAll()
+		return nil, nil, err
+	}
+
+	if cfg.ReplicaDSN != "" {
+		// The replica may be down at boot; reads use the primary until it
+		// passes a health check.
+		rdb, err := gorm.Open(postgres.Open(cfg.ReplicaDSN), &gorm.Config{DisableAutomaticPing: true})
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("replica: %w", err)
+		}
+		replica, err := rdb.DB()
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("replica: %w", err)
+		}
+		tunePool(replica, cfg)
+		closers = append(closers, replica.Close)
+
+		router := newReplicaRouter(replica, log)
+		err = db.Use(dbresolver.Register(dbresolver.Config{
+			// Policy picks between these two in this order.
+			Replicas: []gorm.Dialector{
+				postgres.New(postgres.Config{Conn: replica}),
+				postgres.New(postgres.Config{Conn: primary}),
+			},
+			Policy: router,
+		}))
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("replica routing: %w", err)
+		}
+		watchCtx, stop := context.WithCancel(context.Background())
+		router.check(watchCtx, cfg.ReplicaCheckInterval)
+		go router.watch(watchCtx, cfg.ReplicaCheckInterval)
+		closers = append(closers, func() error { stop(); return nil })
+		log.Infow("read replica configured", "healthy", router.healthy.Load())
+	}
+	return db, closeAll, nil
 }
 
-func getEnv(k, d string) string {
-	if v := os.Getenv(k); v != "" {
-		return v
+// connect opens the primary, retrying until ctx is done.
+func connect(ctx context.Context, dsn string, log *zap.SugaredLogger) (*gorm.DB, error) {
+	delay := minConnectDelay
+	for attempt := 1; ; attempt++ {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		if err == nil {
+			return db, nil
+		}
+		log.Warnw("database unavailable; retrying", "attempt", attempt, "retryIn", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("database unavailable after %d attempts: %w", attempt, err)
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxConnectDelay {
+			delay = maxConnectDelay
+		}
 	}
-	return d
+}
+
+func tunePool(db *sql.DB, cfg config.Database) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 }
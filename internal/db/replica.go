@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// replicaRouter is the dbresolver policy for reads: the replica while its
+// last health check passed, the primary otherwise. It is handed the pools as
+// [replica, primary].
+type replicaRouter struct {
+	replica *sql.DB
+	log     *zap.SugaredLogger
+	healthy atomic.Bool
+}
+
+func newReplicaRouter(replica *sql.DB, log *zap.SugaredLogger) *replicaRouter {
+	return &replicaRouter{replica: replica, log: log}
+}
+
+func (r *replicaRouter) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	if r.healthy.Load() || len(pools) < 2 {
+		return pools[0]
+	}
+	return pools[1]
+}
+
+// watch checks the replica every interval until ctx is done.
+func (r *replicaRouter) watch(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			r.check(ctx, interval)
+		}
+	}
+}
+
+func (r *replicaRouter) check(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := r.replica.PingContext(ctx)
+	if ctx.Err() == context.Canceled {
+		return
+	}
+	ok := err == nil
+	if r.healthy.Swap(ok) != ok {
+		if ok {
+			r.log.Infow("read replica healthy; routing reads to it")
+		} else {
+			r.log.Warnw("read replica unhealthy; routing reads to primary", "err", err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const cancelKey = "playback:query_timeout_cancel"
+
+// queryTimeout is a gorm plugin bounding every query with a deadline on top
+// of the caller's context, so a slow database cannot hold a request past
+// its own timeout or the configured limit, whichever is earlier. Row and Raw
+// are left alone: their rows are read after the callbacks return.
+type queryTimeout time.Duration
+
+func (queryTimeout) Name() string { return "playback:query_timeout" }
+
+func (t queryTimeout) Initialize(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		ctx, cancel := context.WithTimeout(tx.Statement.Context, time.Duration(t))
+		tx.Statement.Context = ctx
+		tx.InstanceSet(cancelKey, cancel)
+	}
+	after := func(tx *gorm.DB) {
+		if cancel, ok := tx.InstanceGet(cancelKey); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Query().Before("gorm:query").Register("playback:timeout_before_query", before),
+		cb.Query().After("gorm:after_query").Register("playback:timeout_after_query", after),
+		cb.Create().Before("gorm:begin_transaction").Register("playback:timeout_before_create", before),
+		cb.Create().After("gorm:commit_or_rollback_transaction").Register("playback:timeout_after_create", after),
+		cb.Update().Before("gorm:begin_transaction").Register("playback:timeout_before_update", before),
+		cb.Update().After("gorm:commit_or_rollback_transaction").Register("playback:timeout_after_update", after),
+		cb.Delete().Before("gorm:begin_transaction").Register("playback:timeout_before_delete", before),
+		cb.Delete().After("gorm:commit_or_rollback_transaction").Register("playback:timeout_after_delete", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package hls
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute is one AttributeName=AttributeValue pair of a tag's attribute
+// list. Quoted values are stored without their quotes.
+type Attribute struct {
+	Key    string
+	Value  string
+	Quoted bool
+}
+
+// parseAttributes splits an attribute list such as
+// BANDWIDTH=1280000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=1280x720.
+func parseAttributes(s string) ([]Attribute, error) {
+	var attrs []Attribute
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("malformed attribute list near %q", s)
+		}
+		a := Attribute{Key: strings.TrimSpace(s[:eq])}
+		s = s[eq+1:]
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string for %s", a.Key)
+			}
+			a.Value, a.Quoted = s[1:end+1], true
+			s = s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			a.Value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		attrs = append(attrs, a)
+		if len(s) > 0 {
+			if s[0] != ',' {
+				return nil, fmt.Errorf("expected ',' after %s", a.Key)
+			}
+			s = s[1:]
+		}
+	}
+	return attrs, nil
+}
+
+func formatAttributes(attrs []Attribute) string {
+	var b strings.Builder
+	for i, a := range attrs {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(a.Key)
+		b.WriteByte('=')
+		if a.Quoted {
+			b.WriteByte('"')
+			b.WriteString(a.Value)
+			b.WriteByte('"')
+		} else {
+			b.WriteString(a.Value)
+		}
+	}
+	return b.String()
+}
+
+// attrWriter builds an attribute list in a fixed order, skipping empty values.
+type attrWriter struct {
+	attrs []Attribute
+}
+
+func (w *attrWriter) quoted(k, v string) {
+	if v != "" {
+		w.attrs = append(w.attrs, Attribute{Key: k, Value: v, Quoted: true})
+	}
+}
+
+func (w *attrWriter) enum(k, v string) {
+	if v != "" {
+		w.attrs = append(w.attrs, Attribute{Key: k, Value: v})
+	}
+}
+
+func (w *attrWriter) int(k string, v int64) {
+	if v != 0 {
+		w.attrs = append(w.attrs, Attribute{Key: k, Value: strconv.FormatInt(v, 10)})
+	}
+}
+
+func (w *attrWriter) float(k string, v float64) {
+	if v != 0 {
+		w.attrs = append(w.attrs, Attribute{Key: k, Value: formatFloat(v)})
+	}
+}
+
+func (w *attrWriter) yes(k string, v bool) {
+	if v {
+		w.attrs = append(w.attrs, Attribute{Key: k, Value: "YES"})
+	}
+}
+
+func (w *attrWriter) String() string { return formatAttributes(w.attrs) }
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func parseInt(a Attribute) (int64, error) {
+	n, err := strconv.ParseInt(a.Value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", a.Key, err)
+	}
+	return n, nil
+}
+
+func parseFloat(a Attribute) (float64, error) {
+	f, err := strconv.ParseFloat(a.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", a.Key, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,335 @@
+// Package hls parses and writes HLS (RFC 8216) master and media playlists.
+//
+// Playlists are decoded into typed structs that can be edited and encoded
+// again; tags and attributes the package does not model are kept verbatim so
+// a decode/encode round trip does not lose information.
+package hls
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var errNoHeader = errors.New("missing #EXTM3U header")
+
+// ParseError reports a malformed playlist.
+type ParseError struct {
+	Err error
+}
+
+func (e *ParseError) Error() string { return "hls: " + e.Err.Error() }
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// Playlist is either a *MasterPlaylist or a *MediaPlaylist.
+type Playlist interface {
+	Encode() []byte
+}
+
+// Decode parses data as a master or media playlist, depending on its tags.
+func Decode(data []byte) (Playlist, error) {
+	if isMaster(data) {
+		return DecodeMaster(data)
+	}
+	return DecodeMedia(data)
+}
+
+// isMaster reports whether the playlist contains master-only tags.
+func isMaster(data []byte) bool {
+	return bytes.Contains(data, []byte("#EXT-X-STREAM-INF")) ||
+		bytes.Contains(data, []byte("#EXT-X-I-FRAME-STREAM-INF")) ||
+		bytes.Contains(data, []byte("#EXT-X-MEDIA:"))
+}
+
+// line is one non-empty playlist line split into tag name and value.
+type line struct {
+	num   int
+	tag   string // "" for URI lines
+	value string // text after ':' for tags, the URI otherwise
+	raw   string
+}
+
+// readLines splits a playlist into lines and validates the header.
+func readLines(data []byte) ([]line, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	var out []line
+	n := 0
+	for sc.Scan() {
+		n++
+		raw := strings.TrimSpace(sc.Text())
+		if n == 1 {
+			raw = strings.TrimPrefix(raw, "\ufeff")
+			if raw != "#EXTM3U" {
+				return nil, errNoHeader
+			}
+			continue
+		}
+		if raw == "" {
+			continue
+		}
+		l := line{num: n, raw: raw}
+		switch {
+		case strings.HasPrefix(raw, "#EXT"):
+			l.tag, l.value, _ = strings.Cut(raw, ":")
+		case strings.HasPrefix(raw, "#"):
+			l.tag = "#" // comment
+		default:
+			l.value = raw
+		}
+		out = append(out, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, errNoHeader
+	}
+	return out, nil
+}
+
+func lineErr(l line, err error) error {
+	return fmt.Errorf("line %d (%s): %w", l.num, l.tag, err)
+}
+
+// Start is the EXT-X-START tag.
+type Start struct {
+	TimeOffset float64
+	Precise    bool
+}
+
+func decodeStart(value string) (*Start, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	s := &Start{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "TIME-OFFSET":
+			if s.TimeOffset, err = parseFloat(a); err != nil {
+				return nil, err
+			}
+		case "PRECISE":
+			s.Precise = a.Value == "YES"
+		}
+	}
+	return s, nil
+}
+
+func (s *Start) encode() string {
+	attrs := []Attribute{{Key: "TIME-OFFSET", Value: formatFloat(s.TimeOffset)}}
+	if s.Precise {
+		attrs = append(attrs, Attribute{Key: "PRECISE", Value: "YES"})
+	}
+	return "#EXT-X-START:" + formatAttributes(attrs)
+}
+
+// Key is an EXT-X-KEY or EXT-X-SESSION-KEY tag.
+type Key struct {
+	Method            string // NONE, AES-128, SAMPLE-AES, ...
+	URI               string
+	IV                string
+	KeyFormat         string
+	KeyFormatVersions string
+	Other             []Attribute
+}
+
+func decodeKey(value string) (*Key, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "METHOD":
+			k.Method = a.Value
+		case "URI":
+			k.URI = a.Value
+		case "IV":
+			k.IV = a.Value
+		case "KEYFORMAT":
+			k.KeyFormat = a.Value
+		case "KEYFORMATVERSIONS":
+			k.KeyFormatVersions = a.Value
+		default:
+			k.Other = append(k.Other, a)
+		}
+	}
+	if k.Method == "" {
+		return nil, errors.New("METHOD is required")
+	}
+	return k, nil
+}
+
+func (k *Key) encode(tag string) string {
+	w := attrWriter{}
+	w.enum("METHOD", k.Method)
+	w.quoted("URI", k.URI)
+	w.enum("IV", k.IV)
+	w.quoted("KEYFORMAT", k.KeyFormat)
+	w.quoted("KEYFORMATVERSIONS", k.KeyFormatVersions)
+	w.attrs = append(w.attrs, k.Other...)
+	return tag + ":" + w.String()
+}
+
+// ByteRange is a sub-range of a resource (EXT-X-BYTERANGE or BYTERANGE=).
+type ByteRange struct {
+	Length int64
+	Offset int64
+	// HasOffset is false when the range continues from the previous one.
+	HasOffset bool
+}
+
+func decodeByteRange(value string) (*ByteRange, error) {
+	n, o, hasOffset := strings.Cut(value, "@")
+	length, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	br := &ByteRange{Length: length, HasOffset: hasOffset}
+	if hasOffset {
+		if br.Offset, err = strconv.ParseInt(o, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return br, nil
+}
+
+func (b *ByteRange) String() string {
+	s := strconv.FormatInt(b.Length, 10)
+	if b.HasOffset {
+		s += "@" + strconv.FormatInt(b.Offset, 10)
+	}
+	return s
+}
+
+// Map is the EXT-X-MAP media initialization section.
+type Map struct {
+	URI       string
+	ByteRange *ByteRange
+	Other     []Attribute
+}
+
+func decodeMap(value string) (*Map, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	m := &Map{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "URI":
+			m.URI = a.Value
+		case "BYTERANGE":
+			if m.ByteRange, err = decodeByteRange(a.Value); err != nil {
+				return nil, fmt.Errorf("BYTERANGE: %w", err)
+			}
+		default:
+			m.Other = append(m.Other, a)
+		}
+	}
+	if m.URI == "" {
+		return nil, errors.New("URI is required")
+	}
+	return m, nil
+}
+
+func (m *Map) encode() string {
+	w := attrWriter{}
+	w.quoted("URI", m.URI)
+	if m.ByteRange != nil {
+		w.quoted("BYTERANGE", m.ByteRange.String())
+	}
+	w.attrs = append(w.attrs, m.Other...)
+	return "#EXT-X-MAP:" + w.String()
+}
+
+// DateRange is an EXT-X-DATERANGE tag. Client (X-*) and SCTE35 attributes
+// are kept in Other.
+type DateRange struct {
+	ID              string
+	Class           string
+	StartDate       string
+	EndDate         string
+	Duration        *float64
+	PlannedDuration *float64
+	EndOnNext       bool
+	Other           []Attribute
+}
+
+func decodeDateRange(value string) (*DateRange, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	d := &DateRange{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "ID":
+			d.ID = a.Value
+		case "CLASS":
+			d.Class = a.Value
+		case "START-DATE":
+			d.StartDate = a.Value
+		case "END-DATE":
+			d.EndDate = a.Value
+		case "DURATION":
+			f, err := parseFloat(a)
+			if err != nil {
+				return nil, err
+			}
+			d.Duration = &f
+		case "PLANNED-DURATION":
+			f, err := parseFloat(a)
+			if err != nil {
+				return nil, err
+			}
+			d.PlannedDuration = &f
+		case "END-ON-NEXT":
+			d.EndOnNext = a.Value == "YES"
+		default:
+			d.Other = append(d.Other, a)
+		}
+	}
+	if d.ID == "" {
+		return nil, errors.New("ID is required")
+	}
+	return d, nil
+}
+
+func (d *DateRange) encode() string {
+	w := attrWriter{}
+	w.quoted("ID", d.ID)
+	w.quoted("CLASS", d.Class)
+	w.quoted("START-DATE", d.StartDate)
+	w.quoted("END-DATE", d.EndDate)
+	if d.Duration != nil {
+		w.attrs = append(w.attrs, Attribute{Key: "DURATION", Value: formatFloat(*d.Duration)})
+	}
+	if d.PlannedDuration != nil {
+		w.attrs = append(w.attrs, Attribute{Key: "PLANNED-DURATION", Value: formatFloat(*d.PlannedDuration)})
+	}
+	w.yes("END-ON-NEXT", d.EndOnNext)
+	w.attrs = append(w.attrs, d.Other...)
+	return "#EXT-X-DATERANGE:" + w.String()
+}
+
+// writer accumulates playlist lines.
+type writer struct {
+	buf bytes.Buffer
+}
+
+func (w *writer) line(s string) {
+	w.buf.WriteString(s)
+	w.buf.WriteByte('\n')
+}
+
+func (w *writer) tag(name, value string) {
+	w.line(name + ":" + value)
+}
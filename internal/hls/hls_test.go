@@ -0,0 +1,305 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// playlist joins lines into a playlist the way the encoder writes it.
+func playlist(lines ...string) string {
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestMasterRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, p *MasterPlaylist)
+	}{
+		{
+			name: "stream-inf",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-VERSION:6",
+				"#EXT-X-INDEPENDENT-SEGMENTS",
+				`#EXT-X-STREAM-INF:BANDWIDTH=2500000,AVERAGE-BANDWIDTH=2200000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720,FRAME-RATE=29.97,CLOSED-CAPTIONS=NONE`,
+				"720p/index.m3u8",
+				`#EXT-X-STREAM-INF:BANDWIDTH=800000,CODECS="avc1.4d401e,mp4a.40.2",RESOLUTION=640x360`,
+				"360p/index.m3u8",
+			),
+			check: func(t *testing.T, p *MasterPlaylist) {
+				if len(p.Variants) != 2 {
+					t.Fatalf("variants = %d, want 2", len(p.Variants))
+				}
+				v := p.Variants[0]
+				if v.Bandwidth != 2500000 || v.Width != 1280 || v.Height != 720 || v.FrameRate != 29.97 || v.URI != "720p/index.m3u8" {
+					t.Errorf("variant = %+v", v)
+				}
+			},
+		},
+		{
+			name: "i-frame-stream-inf",
+			input: playlist(
+				"#EXTM3U",
+				`#EXT-X-STREAM-INF:BANDWIDTH=2500000,CODECS="avc1.64001f",RESOLUTION=1280x720`,
+				"720p/index.m3u8",
+				`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=200000,CODECS="avc1.64001f",RESOLUTION=1280x720,URI="720p/iframe.m3u8"`,
+			),
+			check: func(t *testing.T, p *MasterPlaylist) {
+				if len(p.IFrameVariants) != 1 || p.IFrameVariants[0].URI != "720p/iframe.m3u8" {
+					t.Errorf("I-frame variants = %+v", p.IFrameVariants)
+				}
+			},
+		},
+		{
+			name: "media",
+			input: playlist(
+				"#EXTM3U",
+				`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="2",URI="audio_en/index.m3u8"`,
+				`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="CC1",INSTREAM-ID="CC1"`,
+				`#EXT-X-STREAM-INF:BANDWIDTH=2500000,CODECS="avc1.64001f,mp4a.40.2",AUDIO="aud",CLOSED-CAPTIONS="cc"`,
+				"720p/index.m3u8",
+			),
+			check: func(t *testing.T, p *MasterPlaylist) {
+				if len(p.Media) != 2 {
+					t.Fatalf("media = %d, want 2", len(p.Media))
+				}
+				m := p.Media[0]
+				if m.Type != "AUDIO" || m.GroupID != "aud" || !m.Default || m.URI != "audio_en/index.m3u8" {
+					t.Errorf("media = %+v", m)
+				}
+				if p.Variants[0].Audio != "aud" || p.Variants[0].ClosedCaptions != "cc" {
+					t.Errorf("variant groups = %+v", p.Variants[0])
+				}
+			},
+		},
+		{
+			name: "unknown tags and attributes",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-VERSION:7",
+				"#EXT-X-CONTENT-STEERING:SERVER-URI=\"/steering\"",
+				`#EXT-X-STREAM-INF:BANDWIDTH=2500000,PATHWAY-ID="cdn-a",SCORE=1.5`,
+				"720p/index.m3u8",
+			),
+			check: func(t *testing.T, p *MasterPlaylist) {
+				if len(p.Other) != 1 || !strings.HasPrefix(p.Other[0], "#EXT-X-CONTENT-STEERING") {
+					t.Errorf("other = %q", p.Other)
+				}
+				if len(p.Variants[0].Other) != 2 {
+					t.Errorf("variant other = %+v", p.Variants[0].Other)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := DecodeMaster([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			tt.check(t, p)
+			if got := string(p.Encode()); got != tt.input {
+				t.Errorf("round trip mismatch\ngot:\n%s\nwant:\n%s", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestMediaRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, p *MediaPlaylist)
+	}{
+		{
+			name: "key",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-VERSION:3",
+				"#EXT-X-TARGETDURATION:6",
+				`#EXT-X-KEY:METHOD=AES-128,URI="keys/k1.key",IV=0x00000000000000000000000000000001`,
+				"#EXTINF:6,",
+				"seg0.ts",
+				`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k2",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"`,
+				"#EXTINF:6,",
+				"seg1.ts",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				k := p.Segments[0].Keys[0]
+				if k.Method != "AES-128" || k.URI != "keys/k1.key" || k.IV == "" {
+					t.Errorf("key = %+v", k)
+				}
+				if len(p.Segments[1].Keys) != 1 || p.Segments[1].Keys[0].KeyFormat != "com.apple.streamingkeydelivery" {
+					t.Errorf("second key = %+v", p.Segments[1].Keys)
+				}
+			},
+		},
+		{
+			name: "map and byterange",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-VERSION:7",
+				"#EXT-X-TARGETDURATION:4",
+				"#EXT-X-PLAYLIST-TYPE:VOD",
+				`#EXT-X-MAP:URI="video.mp4",BYTERANGE="720@0"`,
+				"#EXTINF:4,",
+				"#EXT-X-BYTERANGE:100000@720",
+				"video.mp4",
+				"#EXTINF:4,",
+				"#EXT-X-BYTERANGE:98000",
+				"video.mp4",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				m := p.Segments[0].Map
+				if m == nil || m.URI != "video.mp4" || m.ByteRange.Length != 720 || !m.ByteRange.HasOffset {
+					t.Errorf("map = %+v", m)
+				}
+				br := p.Segments[1].ByteRange
+				if br == nil || br.Length != 98000 || br.HasOffset {
+					t.Errorf("byte range = %+v", br)
+				}
+			},
+		},
+		{
+			name: "discontinuity",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-TARGETDURATION:10",
+				"#EXT-X-MEDIA-SEQUENCE:120",
+				"#EXT-X-DISCONTINUITY-SEQUENCE:3",
+				"#EXTINF:9.009,",
+				"a120.ts",
+				"#EXT-X-DISCONTINUITY",
+				"#EXTINF:10,ad break",
+				"ad0.ts",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				if p.MediaSequence != 120 || p.DiscontinuitySequence != 3 {
+					t.Errorf("sequences = %d, %d", p.MediaSequence, p.DiscontinuitySequence)
+				}
+				if p.Segments[0].Discontinuity || !p.Segments[1].Discontinuity || p.Segments[1].Title != "ad break" {
+					t.Errorf("segments = %+v, %+v", p.Segments[0], p.Segments[1])
+				}
+				if p.IsVOD() {
+					t.Error("live playlist reported as VOD")
+				}
+			},
+		},
+		{
+			name: "program date time",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-TARGETDURATION:6",
+				"#EXT-X-PROGRAM-DATE-TIME:2024-03-01T12:00:00.000Z",
+				"#EXTINF:6,",
+				"seg0.ts",
+				"#EXT-X-PROGRAM-DATE-TIME:2024-03-01T12:00:06.000+0000",
+				"#EXTINF:6,",
+				"seg1.ts",
+				"#EXT-X-PROGRAM-DATE-TIME:2024-03-01T13:00:12+01:00",
+				"#EXTINF:6,",
+				"seg2.ts",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+				for i, s := range p.Segments {
+					want := start.Add(time.Duration(i) * 6 * time.Second)
+					if !s.ProgramDateTime.Equal(want) {
+						t.Errorf("segment %d date = %v, want %v", i, s.ProgramDateTime, want)
+					}
+				}
+			},
+		},
+		{
+			name: "endlist",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-TARGETDURATION:6",
+				"#EXTINF:6,",
+				"seg0.ts",
+				"#EXTINF:2.5,",
+				"seg1.ts",
+				"#EXT-X-ENDLIST",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				if !p.EndList || !p.IsVOD() || len(p.Segments) != 2 || p.Segments[1].Duration != 2.5 {
+					t.Errorf("playlist = %+v", p)
+				}
+			},
+		},
+		{
+			name: "unknown tags",
+			input: playlist(
+				"#EXTM3U",
+				"#EXT-X-TARGETDURATION:6",
+				"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES",
+				"#EXTINF:6,",
+				"seg0.ts",
+				"#EXT-X-CUE-OUT:30",
+				"#EXTINF:6,",
+				"seg1.ts",
+				"#EXT-X-CUE-IN",
+			),
+			check: func(t *testing.T, p *MediaPlaylist) {
+				if len(p.Other) != 1 || p.Other[0] != "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES" {
+					t.Errorf("other = %q", p.Other)
+				}
+				if len(p.Segments[1].Other) != 1 || p.Segments[1].Other[0] != "#EXT-X-CUE-OUT:30" {
+					t.Errorf("segment other = %q", p.Segments[1].Other)
+				}
+				if len(p.Trailing) != 1 || p.Trailing[0] != "#EXT-X-CUE-IN" {
+					t.Errorf("trailing = %q", p.Trailing)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := DecodeMedia([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			tt.check(t, p)
+			if got := string(p.Encode()); got != tt.input {
+				t.Errorf("round trip mismatch\ngot:\n%s\nwant:\n%s", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestProgramDateTimeEdited(t *testing.T) {
+	p, err := DecodeMedia([]byte(playlist(
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-03-01T12:00:00.000+0000",
+		"#EXTINF:6,",
+		"seg0.ts",
+	)))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	p.Segments[0].ProgramDateTime = time.Date(2024, 3, 1, 12, 0, 1, 0, time.UTC)
+	if got := string(p.Encode()); !strings.Contains(got, "#EXT-X-PROGRAM-DATE-TIME:2024-03-01T12:00:01Z\n") {
+		t.Errorf("edited date not written:\n%s", got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing header", playlist("#EXT-X-TARGETDURATION:6")},
+		{"stream-inf without uri", playlist("#EXTM3U", "#EXT-X-STREAM-INF:BANDWIDTH=1")},
+		{"bad program date time", playlist("#EXTM3U", "#EXT-X-TARGETDURATION:6", "#EXT-X-PROGRAM-DATE-TIME:yesterday", "#EXTINF:6,", "a.ts")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode([]byte(tt.input)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
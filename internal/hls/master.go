@@ -0,0 +1,390 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MasterPlaylist is a multivariant playlist.
+type MasterPlaylist struct {
+	Version             int
+	IndependentSegments bool
+	Start               *Start
+	Media               []*Media
+	Variants            []*Variant
+	IFrameVariants      []*Variant
+	SessionData         []*SessionData
+	SessionKeys         []*Key
+	// Other holds unrecognised tags (and comments) verbatim.
+	Other []string
+}
+
+// Variant is an EXT-X-STREAM-INF (or EXT-X-I-FRAME-STREAM-INF) entry.
+type Variant struct {
+	URI              string
+	Bandwidth        int64
+	AverageBandwidth int64
+	Codecs           string
+	Width, Height    int
+	FrameRate        float64
+	HDCPLevel        string
+	VideoRange       string
+	Audio            string
+	Video            string
+	Subtitles        string
+	// ClosedCaptions is either a quoted GROUP-ID or the enumerated NONE.
+	ClosedCaptions string
+	Other          []Attribute
+}
+
+// Resolution returns the RESOLUTION attribute value, e.g. "1280x720".
+func (v *Variant) Resolution() string {
+	if v.Width == 0 && v.Height == 0 {
+		return ""
+	}
+	return strconv.Itoa(v.Width) + "x" + strconv.Itoa(v.Height)
+}
+
+// AudioOnly reports whether the variant carries no video: it has no
+// RESOLUTION and lists CODECS, none of which is a video codec. Variants
+// without either are assumed to carry video.
+func (v *Variant) AudioOnly() bool {
+	if v.Width != 0 || v.Height != 0 || strings.TrimSpace(v.Codecs) == "" {
+		return false
+	}
+	for _, c := range strings.Split(v.Codecs, ",") {
+		if IsVideoCodec(strings.TrimSpace(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsVideoCodec reports whether an RFC 6381 codec string, as used in
+// CODECS, names a video codec (AVC, HEVC, AV1, VP9 or Dolby Vision).
+func IsVideoCodec(codec string) bool {
+	for _, p := range []string{"avc1", "avc3", "hvc1", "hev1", "av01", "vp09", "dvh1", "dvhe"} {
+		if strings.HasPrefix(codec, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Media is an EXT-X-MEDIA rendition (alternate audio, subtitles, ...).
+type Media struct {
+	Type            string // AUDIO, VIDEO, SUBTITLES, CLOSED-CAPTIONS
+	GroupID         string
+	Name            string
+	Language        string
+	AssocLanguage   string
+	Default         bool
+	Autoselect      bool
+	Forced          bool
+	InstreamID      string
+	Characteristics string
+	Channels        string
+	URI             string
+	Other           []Attribute
+}
+
+// SessionData is an EXT-X-SESSION-DATA tag.
+type SessionData struct {
+	DataID   string
+	Value    string
+	URI      string
+	Language string
+	Other    []Attribute
+}
+
+// DecodeMaster parses a master playlist.
+func DecodeMaster(data []byte) (*MasterPlaylist, error) {
+	p, err := decodeMasterPlaylist(data)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	return p, nil
+}
+
+func decodeMasterPlaylist(data []byte) (*MasterPlaylist, error) {
+	lines, err := readLines(data)
+	if err != nil {
+		return nil, err
+	}
+	p := &MasterPlaylist{}
+	var pending *Variant
+	for _, l := range lines {
+		switch l.tag {
+		case "":
+			if pending == nil {
+				return nil, fmt.Errorf("line %d: URI %q without EXT-X-STREAM-INF", l.num, l.value)
+			}
+			pending.URI = l.value
+			p.Variants = append(p.Variants, pending)
+			pending = nil
+		case "#EXT-X-VERSION":
+			v, err := strconv.Atoi(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			p.Version = v
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			p.IndependentSegments = true
+		case "#EXT-X-START":
+			if p.Start, err = decodeStart(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+		case "#EXT-X-MEDIA":
+			m, err := decodeMedia(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			p.Media = append(p.Media, m)
+		case "#EXT-X-STREAM-INF":
+			if pending, err = decodeVariant(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+		case "#EXT-X-I-FRAME-STREAM-INF":
+			v, err := decodeVariant(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			if v.URI == "" {
+				return nil, lineErr(l, errors.New("URI is required"))
+			}
+			p.IFrameVariants = append(p.IFrameVariants, v)
+		case "#EXT-X-SESSION-DATA":
+			sd, err := decodeSessionData(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			p.SessionData = append(p.SessionData, sd)
+		case "#EXT-X-SESSION-KEY":
+			k, err := decodeKey(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			p.SessionKeys = append(p.SessionKeys, k)
+		default:
+			p.Other = append(p.Other, l.raw)
+		}
+	}
+	if pending != nil {
+		return nil, errors.New("EXT-X-STREAM-INF without URI")
+	}
+	return p, nil
+}
+
+// Encode serialises the playlist.
+func (p *MasterPlaylist) Encode() []byte {
+	w := &writer{}
+	w.line("#EXTM3U")
+	if p.Version > 0 {
+		w.tag("#EXT-X-VERSION", strconv.Itoa(p.Version))
+	}
+	if p.IndependentSegments {
+		w.line("#EXT-X-INDEPENDENT-SEGMENTS")
+	}
+	if p.Start != nil {
+		w.line(p.Start.encode())
+	}
+	for _, o := range p.Other {
+		w.line(o)
+	}
+	for _, sd := range p.SessionData {
+		w.tag("#EXT-X-SESSION-DATA", sd.encode())
+	}
+	for _, k := range p.SessionKeys {
+		w.line(k.encode("#EXT-X-SESSION-KEY"))
+	}
+	for _, m := range p.Media {
+		w.tag("#EXT-X-MEDIA", m.encode())
+	}
+	for _, v := range p.Variants {
+		w.tag("#EXT-X-STREAM-INF", v.encode(false))
+		w.line(v.URI)
+	}
+	for _, v := range p.IFrameVariants {
+		w.tag("#EXT-X-I-FRAME-STREAM-INF", v.encode(true))
+	}
+	return w.buf.Bytes()
+}
+
+func decodeVariant(value string) (*Variant, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	v := &Variant{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "BANDWIDTH":
+			if v.Bandwidth, err = parseInt(a); err != nil {
+				return nil, err
+			}
+		case "AVERAGE-BANDWIDTH":
+			if v.AverageBandwidth, err = parseInt(a); err != nil {
+				return nil, err
+			}
+		case "CODECS":
+			v.Codecs = a.Value
+		case "RESOLUTION":
+			w, h, ok := strings.Cut(a.Value, "x")
+			if !ok {
+				return nil, fmt.Errorf("RESOLUTION: invalid value %q", a.Value)
+			}
+			if v.Width, err = strconv.Atoi(w); err != nil {
+				return nil, fmt.Errorf("RESOLUTION: %w", err)
+			}
+			if v.Height, err = strconv.Atoi(h); err != nil {
+				return nil, fmt.Errorf("RESOLUTION: %w", err)
+			}
+		case "FRAME-RATE":
+			if v.FrameRate, err = parseFloat(a); err != nil {
+				return nil, err
+			}
+		case "HDCP-LEVEL":
+			v.HDCPLevel = a.Value
+		case "VIDEO-RANGE":
+			v.VideoRange = a.Value
+		case "AUDIO":
+			v.Audio = a.Value
+		case "VIDEO":
+			v.Video = a.Value
+		case "SUBTITLES":
+			v.Subtitles = a.Value
+		case "CLOSED-CAPTIONS":
+			v.ClosedCaptions = a.Value
+		case "URI":
+			v.URI = a.Value
+		default:
+			v.Other = append(v.Other, a)
+		}
+	}
+	if v.Bandwidth == 0 {
+		return nil, errors.New("BANDWIDTH is required")
+	}
+	return v, nil
+}
+
+func (v *Variant) encode(iframe bool) string {
+	w := attrWriter{}
+	w.int("BANDWIDTH", v.Bandwidth)
+	w.int("AVERAGE-BANDWIDTH", v.AverageBandwidth)
+	w.quoted("CODECS", v.Codecs)
+	w.enum("RESOLUTION", v.Resolution())
+	w.float("FRAME-RATE", v.FrameRate)
+	w.enum("HDCP-LEVEL", v.HDCPLevel)
+	w.enum("VIDEO-RANGE", v.VideoRange)
+	w.quoted("AUDIO", v.Audio)
+	w.quoted("VIDEO", v.Video)
+	w.quoted("SUBTITLES", v.Subtitles)
+	if v.ClosedCaptions == "NONE" {
+		w.enum("CLOSED-CAPTIONS", v.ClosedCaptions)
+	} else {
+		w.quoted("CLOSED-CAPTIONS", v.ClosedCaptions)
+	}
+	w.attrs = append(w.attrs, v.Other...)
+	if iframe {
+		w.quoted("URI", v.URI)
+	}
+	return w.String()
+}
+
+func decodeMedia(value string) (*Media, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	m := &Media{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "TYPE":
+			m.Type = a.Value
+		case "GROUP-ID":
+			m.GroupID = a.Value
+		case "NAME":
+			m.Name = a.Value
+		case "LANGUAGE":
+			m.Language = a.Value
+		case "ASSOC-LANGUAGE":
+			m.AssocLanguage = a.Value
+		case "DEFAULT":
+			m.Default = a.Value == "YES"
+		case "AUTOSELECT":
+			m.Autoselect = a.Value == "YES"
+		case "FORCED":
+			m.Forced = a.Value == "YES"
+		case "INSTREAM-ID":
+			m.InstreamID = a.Value
+		case "CHARACTERISTICS":
+			m.Characteristics = a.Value
+		case "CHANNELS":
+			m.Channels = a.Value
+		case "URI":
+			m.URI = a.Value
+		default:
+			m.Other = append(m.Other, a)
+		}
+	}
+	if m.Type == "" || m.GroupID == "" || m.Name == "" {
+		return nil, errors.New("TYPE, GROUP-ID and NAME are required")
+	}
+	return m, nil
+}
+
+func (m *Media) encode() string {
+	w := attrWriter{}
+	w.enum("TYPE", m.Type)
+	w.quoted("GROUP-ID", m.GroupID)
+	w.quoted("NAME", m.Name)
+	w.quoted("LANGUAGE", m.Language)
+	w.quoted("ASSOC-LANGUAGE", m.AssocLanguage)
+	w.yes("DEFAULT", m.Default)
+	w.yes("AUTOSELECT", m.Autoselect)
+	w.yes("FORCED", m.Forced)
+	w.quoted("INSTREAM-ID", m.InstreamID)
+	w.quoted("CHARACTERISTICS", m.Characteristics)
+	w.quoted("CHANNELS", m.Channels)
+	w.quoted("URI", m.URI)
+	w.attrs = append(w.attrs, m.Other...)
+	return w.String()
+}
+
+func decodeSessionData(value string) (*SessionData, error) {
+	attrs, err := parseAttributes(value)
+	if err != nil {
+		return nil, err
+	}
+	sd := &SessionData{}
+	for _, a := range attrs {
+		switch a.Key {
+		case "DATA-ID":
+			sd.DataID = a.Value
+		case "VALUE":
+			sd.Value = a.Value
+		case "URI":
+			sd.URI = a.Value
+		case "LANGUAGE":
+			sd.Language = a.Value
+		default:
+			sd.Other = append(sd.Other, a)
+		}
+	}
+	if sd.DataID == "" {
+		return nil, errors.New("DATA-ID is required")
+	}
+	return sd, nil
+}
+
+func (sd *SessionData) encode() string {
+	w := attrWriter{}
+	w.quoted("DATA-ID", sd.DataID)
+	w.quoted("VALUE", sd.Value)
+	w.quoted("URI", sd.URI)
+	w.quoted("LANGUAGE", sd.Language)
+	w.attrs = append(w.attrs, sd.Other...)
+	return w.String()
+}
@@ -0,0 +1,318 @@
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MediaPlaylist is a media (variant) playlist.
+type MediaPlaylist struct {
+	Version               int
+	TargetDuration        int64
+	MediaSequence         int64
+	DiscontinuitySequence int64
+	PlaylistType          string // VOD, EVENT or "" for live
+	IFramesOnly           bool
+	IndependentSegments   bool
+	Start                 *Start
+	EndList               bool
+	Segments              []*Segment
+	// Other holds unrecognised playlist-level tags (and comments) verbatim.
+	Other []string
+	// Trailing holds tags that follow the last segment, e.g. a final DATERANGE.
+	Trailing []string
+}
+
+// Segment is one media segment together with the tags that precede it.
+type Segment struct {
+	URI           string
+	Duration      float64
+	Title         string
+	ByteRange     *ByteRange
+	Discontinuity bool
+	// Keys and Map are set on the segment where they take effect and apply
+	// to every following segment until replaced.
+	Keys            []*Key
+	Map             *Map
+	ProgramDateTime time.Time
+	DateRanges      []*DateRange
+	// programDateTime is the tag value as written, re-emitted while it
+	// still denotes ProgramDateTime so offsets like +0000 survive.
+	programDateTime string
+	Gap             bool
+	Bitrate         int64
+	// Other holds unrecognised tags preceding the segment verbatim.
+	Other []string
+}
+
+// IsVOD reports whether the playlist is complete and will not change.
+func (p *MediaPlaylist) IsVOD() bool {
+	return p.PlaylistType == "VOD" || p.EndList
+}
+
+// Duration is the sum of all segment durations in seconds.
+func (p *MediaPlaylist) Duration() float64 {
+	var d float64
+	for _, s := range p.Segments {
+		d += s.Duration
+	}
+	return d
+}
+
+// DecodeMedia parses a media playlist.
+func DecodeMedia(data []byte) (*MediaPlaylist, error) {
+	p, err := decodeMediaPlaylist(data)
+	if err != nil {
+		return nil, &ParseError{Err: err}
+	}
+	return p, nil
+}
+
+func decodeMediaPlaylist(data []byte) (*MediaPlaylist, error) {
+	lines, err := readLines(data)
+	if err != nil {
+		return nil, err
+	}
+	p := &MediaPlaylist{}
+	seg := &Segment{}
+	// started is set once a segment-level tag has been seen, so unknown
+	// tags from then on belong to segments rather than the header.
+	started := false
+	haveInf := false
+	for _, l := range lines {
+		switch l.tag {
+		case "":
+			if !haveInf {
+				return nil, fmt.Errorf("line %d: URI %q without EXTINF", l.num, l.value)
+			}
+			seg.URI = l.value
+			p.Segments = append(p.Segments, seg)
+			seg, haveInf = &Segment{}, false
+			continue
+		case "#EXT-X-VERSION":
+			v, err := strconv.Atoi(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			p.Version = v
+			continue
+		case "#EXT-X-TARGETDURATION":
+			if p.TargetDuration, err = strconv.ParseInt(l.value, 10, 64); err != nil {
+				return nil, lineErr(l, err)
+			}
+			continue
+		case "#EXT-X-MEDIA-SEQUENCE":
+			if p.MediaSequence, err = strconv.ParseInt(l.value, 10, 64); err != nil {
+				return nil, lineErr(l, err)
+			}
+			continue
+		case "#EXT-X-DISCONTINUITY-SEQUENCE":
+			if p.DiscontinuitySequence, err = strconv.ParseInt(l.value, 10, 64); err != nil {
+				return nil, lineErr(l, err)
+			}
+			continue
+		case "#EXT-X-PLAYLIST-TYPE":
+			p.PlaylistType = l.value
+			continue
+		case "#EXT-X-I-FRAMES-ONLY":
+			p.IFramesOnly = true
+			continue
+		case "#EXT-X-INDEPENDENT-SEGMENTS":
+			p.IndependentSegments = true
+			continue
+		case "#EXT-X-START":
+			if p.Start, err = decodeStart(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+			continue
+		case "#EXT-X-ENDLIST":
+			p.EndList = true
+			continue
+		}
+
+		// Segment-level tags.
+		switch l.tag {
+		case "#EXTINF":
+			d, title, _ := strings.Cut(l.value, ",")
+			if seg.Duration, err = strconv.ParseFloat(strings.TrimSpace(d), 64); err != nil {
+				return nil, lineErr(l, err)
+			}
+			seg.Title = title
+			haveInf = true
+		case "#EXT-X-BYTERANGE":
+			if seg.ByteRange, err = decodeByteRange(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+		case "#EXT-X-DISCONTINUITY":
+			seg.Discontinuity = true
+		case "#EXT-X-KEY":
+			k, err := decodeKey(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			seg.Keys = append(seg.Keys, k)
+		case "#EXT-X-MAP":
+			if seg.Map, err = decodeMap(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+		case "#EXT-X-PROGRAM-DATE-TIME":
+			if seg.ProgramDateTime, err = parseDateTime(l.value); err != nil {
+				return nil, lineErr(l, err)
+			}
+			seg.programDateTime = l.value
+		case "#EXT-X-DATERANGE":
+			dr, err := decodeDateRange(l.value)
+			if err != nil {
+				return nil, lineErr(l, err)
+			}
+			seg.DateRanges = append(seg.DateRanges, dr)
+		case "#EXT-X-GAP":
+			seg.Gap = true
+		case "#EXT-X-BITRATE":
+			if seg.Bitrate, err = strconv.ParseInt(l.value, 10, 64); err != nil {
+				return nil, lineErr(l, err)
+			}
+		default:
+			if !started {
+				p.Other = append(p.Other, l.raw)
+				continue
+			}
+			seg.Other = append(seg.Other, l.raw)
+		}
+		started = true
+	}
+	if haveInf {
+		return nil, errors.New("EXTINF without URI")
+	}
+	// Tags after the last segment are kept in order.
+	p.Trailing = seg.encodeTags()
+	return p, nil
+}
+
+// Encode serialises the playlist.
+func (p *MediaPlaylist) Encode() []byte {
+	w := &writer{}
+	w.line("#EXTM3U")
+	if p.Version > 0 {
+		w.tag("#EXT-X-VERSION", strconv.Itoa(p.Version))
+	}
+	w.tag("#EXT-X-TARGETDURATION", strconv.FormatInt(p.TargetDuration, 10))
+	if p.MediaSequence > 0 {
+		w.tag("#EXT-X-MEDIA-SEQUENCE", strconv.FormatInt(p.MediaSequence, 10))
+	}
+	if p.DiscontinuitySequence > 0 {
+		w.tag("#EXT-X-DISCONTINUITY-SEQUENCE", strconv.FormatInt(p.DiscontinuitySequence, 10))
+	}
+	if p.PlaylistType != "" {
+		w.tag("#EXT-X-PLAYLIST-TYPE", p.PlaylistType)
+	}
+	if p.IFramesOnly {
+		w.line("#EXT-X-I-FRAMES-ONLY")
+	}
+	if p.IndependentSegments {
+		w.line("#EXT-X-INDEPENDENT-SEGMENTS")
+	}
+	if p.Start != nil {
+		w.line(p.Start.encode())
+	}
+	for _, o := range p.Other {
+		w.line(o)
+	}
+	for _, s := range p.Segments {
+		for _, t := range s.encodeTags() {
+			w.line(t)
+		}
+		w.line("#EXTINF:" + formatFloat(s.Duration) + "," + s.Title)
+		if s.ByteRange != nil {
+			w.tag("#EXT-X-BYTERANGE", s.ByteRange.String())
+		}
+		w.line(s.URI)
+	}
+	for _, t := range p.Trailing {
+		w.line(t)
+	}
+	if p.EndList {
+		w.line("#EXT-X-ENDLIST")
+	}
+	return w.buf.Bytes()
+}
+
+// encodeTags renders the tags that precede the segment's EXTINF.
+func (s *Segment) encodeTags() []string {
+	var out []string
+	out = append(out, s.Other...)
+	if s.Discontinuity {
+		out = append(out, "#EXT-X-DISCONTINUITY")
+	}
+	for _, k := range s.Keys {
+		out = append(out, k.encode("#EXT-X-KEY"))
+	}
+	if s.Map != nil {
+		out = append(out, s.Map.encode())
+	}
+	if !s.ProgramDateTime.IsZero() {
+		value := s.ProgramDateTime.Format(time.RFC3339Nano)
+		if t, err := parseDateTime(s.programDateTime); err == nil && t.Equal(s.ProgramDateTime) {
+			value = s.programDateTime
+		}
+		out = append(out, "#EXT-X-PROGRAM-DATE-TIME:"+value)
+	}
+	for _, dr := range s.DateRanges {
+		out = append(out, dr.encode())
+	}
+	if s.Gap {
+		out = append(out, "#EXT-X-GAP")
+	}
+	if s.Bitrate > 0 {
+		out = append(out, "#EXT-X-BITRATE:"+strconv.FormatInt(s.Bitrate, 10))
+	}
+	return out
+}
+
+// dateTimeLayouts are the ISO 8601 forms RFC 8216 allows for
+// EXT-X-PROGRAM-DATE-TIME. Fractional seconds are accepted by all of them.
+var dateTimeLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05Z07",
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	var first error
+	for _, layout := range dateTimeLayouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return time.Time{}, first
+}
+
+// SegmentPath returns the path of a segment or init section URI relative to
+// its media playlist, without query. Relative URIs keep their
+// subdirectories; absolute ones and those leaving the playlist's directory
+// are reduced to their file name, as if stored next to the playlist.
+func SegmentPath(uri string) string {
+	p := uri
+	if u, err := url.Parse(uri); err == nil {
+		p = u.Path
+	} else if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		p = uri[:i]
+	}
+	if strings.Contains(uri, "://") {
+		return path.Base(p)
+	}
+	p = path.Clean(p)
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return path.Base(p)
+	}
+	return p
+}
@@ -0,0 +1,196 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+package metrics
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sony/gobreaker"
+	"gorm.io/gorm"
+
+	"github.com/streamhive/playback-service/internal/cache"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_http_requests_total",
+		Help: "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "playback_http_request_duration_seconds",
+		Help:    "HTTP request latency by route, method and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	bytesServed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_bytes_served_total",
+		Help: "Response body bytes of successful requests by route and rendition.",
+	}, []string{"route", "rendition"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_cache_lookups_total",
+		Help: "Object cache lookups by object type and result (hit|miss).",
+	}, []string{"kind", "result"})
+
+	blobAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_blob_attempts_total",
+		Help: "Blob store attempts by operation, including retries.",
+	}, []string{"op"})
+
+	blobRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_blob_retries_total",
+		Help: "Blob store retries by operation.",
+	}, []string{"op"})
+
+	blobFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_blob_failures_total",
+		Help: "Blob store operations that failed after all retries, by operation.",
+	}, []string{"op"})
+
+	breakerTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "playback_breaker_transitions_total",
+		Help: "Circuit breaker state changes.",
+	}, []string{"name", "from", "to"})
+
+	breakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "playback_breaker_state",
+		Help: "Circuit breaker state (0 closed, 1 half-open, 2 open).",
+	}, []string{"name"})
+
+	dbDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "playback_db_query_duration_seconds",
+		Help:    "Database query latency by table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"table"})
+)
+
+// Handler serves the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records request counts, latency and bytes served. Routes are
+// labelled with their pattern so IDs do not blow up cardinality.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(route, c.Request.Method, status).Inc()
+		requestDuration.WithLabelValues(route, c.Request.Method, status).Observe(time.Since(start).Seconds())
+		if s := c.Writer.Status(); s >= 200 && s < 300 && c.Writer.Size() > 0 {
+			bytesServed.WithLabelValues(route, c.Param("rendition")).Add(float64(c.Writer.Size()))
+		}
+	}
+}
+
+// CacheLookup counts an object cache hit or miss.
+func CacheLookup(kind string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(kind, result).Inc()
+}
+
+// BlobAttempt counts one blob store attempt; retry is true for all but the
+// first attempt of an operation.
+func BlobAttempt(op string, retry bool) {
+	blobAttempts.WithLabelValues(op).Inc()
+	if retry {
+		blobRetries.WithLabelValues(op).Inc()
+	}
+}
+
+// BlobFailure counts an operation that failed for good.
+func BlobFailure(op string) {
+	blobFailures.WithLabelValues(op).Inc()
+}
+
+// BreakerStateChange is a gobreaker OnStateChange hook.
+func BreakerStateChange(name string, from, to gobreaker.State) {
+	breakerTransitions.WithLabelValues(name, from.String(), to.String()).Inc()
+	breakerState.WithLabelValues(name).Set(float64(to))
+}
+
+// cacheStats is the source of the cache tier metrics, set by SetCacheStats.
+var cacheStats atomic.Pointer[func() cache.Stats]
+
+func init() {
+	tiers := []struct {
+		tier, result string
+		pick         func(cache.Stats) int64
+	}{
+		{"local", "hit", func(s cache.Stats) int64 { return s.LocalHits }},
+		{"local", "miss", func(s cache.Stats) int64 { return s.LocalMisses }},
+		{"redis", "hit", func(s cache.Stats) int64 { return s.RedisHits }},
+		{"redis", "miss", func(s cache.Stats) int64 { return s.RedisMisses }},
+	}
+	for _, t := range tiers {
+		pick := t.pick
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Name:        "playback_cache_tier_lookups_total",
+			Help:        "Cache lookups by tier and result.",
+			ConstLabels: prometheus.Labels{"tier": t.tier, "result": t.result},
+		}, func() float64 { return float64(pick(currentCacheStats())) })
+	}
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "playback_cache_local_bytes",
+		Help: "Bytes held by the in-process cache tier.",
+	}, func() float64 { return float64(currentCacheStats().LocalBytes) })
+}
+
+// SetCacheStats makes the cache tier metrics report from stats.
+func SetCacheStats(stats func() cache.Stats) {
+	cacheStats.Store(&stats)
+}
+
+func currentCacheStats() cache.Stats {
+	if f := cacheStats.Load(); f != nil {
+		return (*f)()
+	}
+	return cache.Stats{}
+}
+
+// InstrumentDB records query latency through gorm callbacks.
+func InstrumentDB(db *gorm.DB) error {
+	const startKey = "metrics:start"
+	before := func(tx *gorm.DB) { tx.InstanceSet(startKey, time.Now()) }
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+		table := tx.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		dbDuration.WithLabelValues(table).Observe(time.Since(start).Seconds())
+	}
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("metrics:before_query", before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("metrics:after_query", after); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("metrics:before_row", before); err != nil {
+		return err
+	}
+	return cb.Row().After("gorm:row").Register("metrics:after_row", after)
+}
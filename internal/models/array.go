@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StringArray is a PostgreSQL text[] column. It reads and writes the array
+// literal form ({a,"b c",NULL}) so elements may contain commas, quotes,
+// backslashes and braces. NULL elements are dropped on read; a NULL column
+// reads as a nil slice.
+type StringArray []string
+
+var errArraySyntax = errors.New("malformed array literal")
+
+// Scan implements sql.Scanner.
+func (a *StringArray) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return a.parse(string(s))
+	case string:
+		return a.parse(s)
+	}
+	return fmt.Errorf("cannot scan %T into StringArray", src)
+}
+
+// Value implements driver.Valuer. Every element is quoted, which is always
+// valid and avoids deciding which characters need it.
+func (a StringArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, s := range a {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('"')
+		for j := 0; j < len(s); j++ {
+			if s[j] == '"' || s[j] == '\\' {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(s[j])
+		}
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String(), nil
+}
+
+// GormDataType tells gorm the column type for migrations.
+func (StringArray) GormDataType() string { return "text[]" }
+
+// parse decodes a one-dimensional array literal. An optional dimension
+// decoration ("[1:2]={...}") is accepted and ignored.
+func (a *StringArray) parse(s string) error {
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return fmt.Errorf("%w: %q", errArraySyntax, s)
+		}
+		s = s[i+1:]
+	}
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return fmt.Errorf("%w: %q", errArraySyntax, s)
+	}
+	body := s[1 : len(s)-1]
+	out := StringArray{}
+	if strings.TrimSpace(body) == "" {
+		*a = out
+		return nil
+	}
+	for i := 0; ; {
+		for i < len(body) && isArraySpace(body[i]) {
+			i++
+		}
+		var (
+			elem   strings.Builder
+			quoted bool
+		)
+		if i < len(body) && body[i] == '"' {
+			quoted = true
+			i++
+			for {
+				if i >= len(body) {
+					return fmt.Errorf("%w: unterminated quoted element", errArraySyntax)
+				}
+				c := body[i]
+				i++
+				if c == '"' {
+					break
+				}
+				if c == '\\' {
+					if i >= len(body) {
+						return fmt.Errorf("%w: trailing backslash", errArraySyntax)
+					}
+					c = body[i]
+					i++
+				}
+				elem.WriteByte(c)
+			}
+			for i < len(body) && isArraySpace(body[i]) {
+				i++
+			}
+		} else {
+			// Unquoted elements end at the next delimiter; surrounding
+			// whitespace is not part of the value but escaped whitespace is.
+			trail := 0
+			for i < len(body) && body[i] != ',' {
+				c := body[i]
+				i++
+				switch {
+				case c == '{' || c == '}' || c == '"':
+					return fmt.Errorf("%w: unexpected %q", errArraySyntax, c)
+				case c == '\\':
+					if i >= len(body) {
+						return fmt.Errorf("%w: trailing backslash", errArraySyntax)
+					}
+					elem.WriteByte(body[i])
+					i++
+					trail = elem.Len()
+				default:
+					elem.WriteByte(c)
+					if !isArraySpace(c) {
+						trail = elem.Len()
+					}
+				}
+			}
+			v := elem.String()[:trail]
+			if v == "" {
+				return fmt.Errorf("%w: empty element", errArraySyntax)
+			}
+			elem.Reset()
+			elem.WriteString(v)
+		}
+		if v := elem.String(); quoted || !strings.EqualFold(v, "NULL") {
+			out = append(out, v)
+		}
+		if i >= len(body) {
+			break
+		}
+		if body[i] != ',' {
+			return fmt.Errorf("%w: expected ',' after element", errArraySyntax)
+		}
+		i++
+	}
+	*a = out
+	return nil
+}
+
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
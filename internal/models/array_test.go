@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStringArrayScan(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+		want StringArray
+	}{
+		{"null column", nil, nil},
+		{"empty", "{}", StringArray{}},
+		{"unquoted", "{sports,news}", StringArray{"sports", "news"}},
+		{"bytes", []byte("{a,b}"), StringArray{"a", "b"}},
+		{"quoted comma", `{"rock, pop",jazz}`, StringArray{"rock, pop", "jazz"}},
+		{"escaped quote", `{"say \"hi\"","back\\slash"}`, StringArray{`say "hi"`, `back\slash`}},
+		{"quoted braces", `{"{x}"}`, StringArray{"{x}"}},
+		{"null element dropped", "{a,NULL,null,b}", StringArray{"a", "b"}},
+		{"quoted null kept", `{"NULL"}`, StringArray{"NULL"}},
+		{"whitespace", `{ a b , "c " }`, StringArray{"a b", "c "}},
+		{"escaped unquoted", `{a\,b}`, StringArray{"a,b"}},
+		{"dimension decoration", "[1:2]={a,b}", StringArray{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got StringArray
+			if err := got.Scan(tt.src); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scan = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStringArrayScanRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		src  interface{}
+	}{
+		{"empty element", "{a,,b}"},
+		{"trailing comma", "{a,}"},
+		{"multi-dimensional", "{{a}}"},
+		{"nested after element", "{a,{b}}"},
+		{"unterminated quote", `{"a}`},
+		{"missing braces", "a,b"},
+		{"junk after quoted", `{"a"b}`},
+		{"unsupported type", 42},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got StringArray
+			err := got.Scan(tt.src)
+			if err == nil {
+				t.Fatalf("Scan(%v) = %#v, want error", tt.src, got)
+			}
+			if _, ok := tt.src.(string); ok && !errors.Is(err, errArraySyntax) {
+				t.Errorf("error = %v, want errArraySyntax", err)
+			}
+		})
+	}
+}
+
+func TestStringArrayValue(t *testing.T) {
+	tests := []struct {
+		name string
+		in   StringArray
+		want interface{}
+	}{
+		{"nil", nil, nil},
+		{"empty", StringArray{}, "{}"},
+		{"plain", StringArray{"a", "b"}, `{"a","b"}`},
+		{"special", StringArray{`a,b`, `"q"`, `\`, "NULL", "{}", " "}, `{"a,b","\"q\"","\\","NULL","{}"," "}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := tt.in.Value()
+			if err != nil {
+				t.Fatalf("Value: %v", err)
+			}
+			if v != tt.want {
+				t.Fatalf("Value = %#v, want %#v", v, tt.want)
+			}
+			var back StringArray
+			if err := back.Scan(v); err != nil {
+				t.Fatalf("Scan(Value): %v", err)
+			}
+			if !reflect.DeepEqual(back, tt.in) {
+				t.Errorf("round trip = %#v, want %#v", back, tt.in)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// VideoKey is an HLS content key (AES-128 / SAMPLE-AES) of a video. KeyID is
+// the name the transcoder used in the #EXT-X-KEY URI.
+type VideoKey struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	UploadID  string    `gorm:"uniqueIndex:idx_video_keys_upload_key" json:"-"`
+	KeyID     string    `gorm:"uniqueIndex:idx_video_keys_upload_key" json:"-"`
+	Key       []byte    `gorm:"type:bytea" json:"-"`
+	CreatedAt time.Time `json:"-"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// VideoShare grants a user access to someone else's private video.
+type VideoShare struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UploadID  string     `gorm:"index:idx_video_shares_upload_user" json:"upload_id"`
+	UserID    string     `gorm:"index:idx_video_shares_upload_user" json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
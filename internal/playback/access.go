@@ -0,0 +1,117 @@
+package playback
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/streamhive/playback-service/internal/auth"
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// Authenticate returns the bearer token middleware for the playback routes.
+func (h *Handler) Authenticate() gin.HandlerFunc {
+	return auth.Middleware(h.verifier, h.log)
+}
+
+// authorize allows public videos to everyone and private videos to their
+// owner and users holding an unexpired share grant. On denial it writes the
+// response and returns false.
+func (h *Handler) authorize(c *gin.Context, v *models.Video) bool {
+	if !v.IsPrivate {
+		return true
+	}
+	claims := auth.FromContext(c)
+	if claims == nil {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.String(http.StatusUnauthorized, "authentication required")
+		return false
+	}
+	if claims.UserID() == v.UserID {
+		return true
+	}
+	ok, err := h.hasShare(c, v.UploadID, claims.UserID())
+	if err != nil {
+		h.log.Errorw("share lookup", "uploadId", v.UploadID, "err", err)
+		c.String(http.StatusInternalServerError, "authorization failed")
+		return false
+	}
+	if !ok {
+		c.String(http.StatusForbidden, "forbidden")
+		return false
+	}
+	return true
+}
+
+func (h *Handler) hasShare(c *gin.Context, uploadID, userID string) (bool, error) {
+	var n int64
+	err := h.db.WithContext(c.Request.Context()).Model(&models.VideoShare{}).
+		Where("upload_id = ? AND user_id = ? AND (expires_at IS NULL OR expires_at > ?)", uploadID, userID, time.Now()).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// mediaGrant is the outcome of authorizing a playlist or media request: the
+// signed token to carry into rewritten playlists, if URL signing is enabled.
+type mediaGrant struct {
+	raw   string
+	token *auth.PlaybackToken
+}
+
+// query returns the query string to append to rewritten URIs.
+func (g *mediaGrant) query() string {
+	if g.raw == "" {
+		return ""
+	}
+	return "token=" + url.QueryEscape(g.raw)
+}
+
+// allowsRendition reports whether the grant covers a rendition.
+func (g *mediaGrant) allowsRendition(name string) bool {
+	return g.token == nil || g.token.AllowsRendition(name)
+}
+
+// authorizeMedia authorizes playlist, segment and thumbnail requests. A
+// signed ?token= is verified statelessly; without one the caller falls back
+// to bearer authorization, unless signed URLs are required. An empty
+// rendition is used for requests that are not rendition-specific.
+func (h *Handler) authorizeMedia(c *gin.Context, v *models.Video, rendition string) (*mediaGrant, bool) {
+	if raw := c.Query("token"); raw != "" && h.signer != nil {
+		tok, err := h.signer.Verify(raw)
+		if err == nil {
+			err = tok.Allows(v.UploadID, rendition, c.ClientIP())
+		}
+		if err != nil {
+			c.String(http.StatusForbidden, err.Error())
+			return nil, false
+		}
+		return &mediaGrant{raw: raw, token: tok}, true
+	}
+	if h.requireSigned && auth.FromContext(c) == nil {
+		c.String(http.StatusUnauthorized, "signed playback URL required")
+		return nil, false
+	}
+	if !h.authorize(c, v) {
+		return nil, false
+	}
+	if h.signer == nil {
+		return &mediaGrant{}, true
+	}
+	// Bearer-authorized callers may play the whole ladder.
+	raw, tok := h.signer.Issue(v.UploadID, c.ClientIP(), nil)
+	return &mediaGrant{raw: raw, token: tok}, true
+}
+
+// cacheHeaders applies the cache policy for a response. Personalised
+// responses (those embedding a playback token) are treated like private
+// videos so shared caches never hand one viewer's token to another.
+func (h *Handler) cacheHeaders(c *gin.Context, kind cachepolicy.Kind, v *models.Video, personal, live bool) {
+	h.cachePolicy.Decide(cachepolicy.Request{
+		Kind:    kind,
+		Private: v.IsPrivate || personal,
+		Live:    live,
+	}).Apply(c.Writer.Header())
+}
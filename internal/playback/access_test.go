@@ -0,0 +1,220 @@
+package playback
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/streamhive/playback-service/internal/auth"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+const testJWTSecret = "jwt-test-secret"
+
+// shareDB is a database/sql connector that answers the share grant count
+// query from a fixed set of upload/user pairs.
+type shareDB struct {
+	shares map[[2]string]bool
+}
+
+func (d *shareDB) Connect(context.Context) (driver.Conn, error) { return shareConn{d}, nil }
+func (d *shareDB) Driver() driver.Driver                        { return nil }
+
+type shareConn struct{ db *shareDB }
+
+func (shareConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (shareConn) Close() error                        { return nil }
+func (shareConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c shareConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "video_shares") || len(args) < 2 {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	var n int64
+	if c.db.shares[[2]string{fmt.Sprint(args[0].Value), fmt.Sprint(args[1].Value)}] {
+		n = 1
+	}
+	return &countRows{n: n}, nil
+}
+
+type countRows struct {
+	n    int64
+	done bool
+}
+
+func (r *countRows) Columns() []string { return []string{"count"} }
+func (r *countRows) Close() error      { return nil }
+
+func (r *countRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.n
+	return nil
+}
+
+// authRoutes serves the playback routes behind the bearer middleware.
+func (th *testHandler) authRoutes() {
+	r := gin.New()
+	r.Use(th.h.Authenticate())
+	r.GET("/playback/videos/:uploadId", th.h.GetDescriptor)
+	r.GET("/playback/videos/:uploadId/master.m3u8", th.h.GetMaster)
+	r.GET("/playback/videos/:uploadId/:rendition/*file", th.h.GetRenditionFile)
+	th.router = r
+}
+
+func bearer(t *testing.T, user string) http.Header {
+	t.Helper()
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   user,
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return http.Header{"Authorization": {"Bearer " + raw}}
+}
+
+func TestPrivateVideoAccess(t *testing.T) {
+	th := newTestHandler(t)
+	verifier, err := auth.NewVerifier(testJWTSecret, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th.h.verifier = verifier
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(&shareDB{
+		shares: map[[2]string]bool{{"p1", "friend"}: true},
+	})}), &gorm.Config{DisableAutomaticPing: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	th.h.db = db
+	th.h.videos.entries.Set("p1", &models.Video{UploadID: "p1", UserID: "owner", IsPrivate: true, HLSMasterURL: "videos/u1/master.m3u8"})
+
+	th.authRoutes()
+
+	tests := []struct {
+		name   string
+		header http.Header
+		code   int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"owner", bearer(t, "owner"), http.StatusOK},
+		{"share recipient", bearer(t, "friend"), http.StatusOK},
+		{"stranger", bearer(t, "stranger"), http.StatusForbidden},
+		{"invalid bearer", http.Header{"Authorization": {"Bearer junk"}}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, target := range []string{
+				"/playback/videos/p1",
+				"/playback/videos/p1/master.m3u8",
+				"/playback/videos/p1/720p/index.m3u8",
+				"/playback/videos/p1/720p/seg0.ts",
+			} {
+				if w := th.do(http.MethodGet, target, tt.header); w.Code != tt.code {
+					t.Errorf("GET %s: %d, want %d", target, w.Code, tt.code)
+				}
+			}
+		})
+	}
+
+	// Public videos stay playable without credentials.
+	if w := th.do(http.MethodGet, "/playback/videos/u1/720p/seg0.ts", nil); w.Code != http.StatusOK {
+		t.Errorf("public segment: %d", w.Code)
+	}
+}
+
+func TestSignedURLs(t *testing.T) {
+	th := newTestHandler(t)
+	signer := auth.NewURLSigner("url-key", time.Hour, true)
+	th.h.signer = signer
+	th.h.requireSigned = true
+	th.authRoutes()
+	const segment = "/playback/videos/u1/720p/seg0.ts"
+
+	// descriptor returns the status, token and listed renditions.
+	descriptor := func(query string) (int, string, []string) {
+		w := th.do(http.MethodGet, "/playback/videos/u1"+query, nil)
+		var d struct {
+			Token      string `json:"token"`
+			Renditions []struct {
+				Name string `json:"name"`
+			} `json:"renditions"`
+		}
+		var names []string
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+				t.Fatalf("descriptor: %v", err)
+			}
+			for _, r := range d.Renditions {
+				names = append(names, r.Name)
+			}
+		}
+		return w.Code, d.Token, names
+	}
+
+	if w := th.do(http.MethodGet, segment, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned segment: %d", w.Code)
+	}
+	_, token, names := descriptor("")
+	if token == "" || len(names) != 1 {
+		t.Fatalf("descriptor: token %q, renditions %v", token, names)
+	}
+	if w := th.do(http.MethodGet, segment+"?token="+token, nil); w.Code != http.StatusOK {
+		t.Errorf("signed segment: %d %s", w.Code, w.Body.String())
+	}
+
+	// httptest requests come from 192.0.2.1.
+	forged := map[string]*auth.PlaybackToken{
+		"other upload": {UploadID: "u2", Expires: time.Now().Add(time.Hour).Unix()},
+		"other ip":     {UploadID: "u1", Expires: time.Now().Add(time.Hour).Unix(), ClientIP: "198.51.100.7"},
+		"expired":      {UploadID: "u1", Expires: time.Now().Add(-time.Minute).Unix()},
+	}
+	for name, tok := range forged {
+		if w := th.do(http.MethodGet, segment+"?token="+url.QueryEscape(signer.Sign(tok)), nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s: %d", name, w.Code)
+		}
+	}
+	other := auth.NewURLSigner("other-key", time.Hour, false)
+	if w := th.do(http.MethodGet, segment+"?token="+url.QueryEscape(mustToken(other)), nil); w.Code != http.StatusForbidden {
+		t.Errorf("token from another key: %d", w.Code)
+	}
+
+	// A token narrowed to other renditions does not cover 720p.
+	code, token, names := descriptor("?renditions=1080p,360p")
+	if code != http.StatusOK || len(names) != 0 {
+		t.Fatalf("restricted descriptor: %d, renditions %v", code, names)
+	}
+	if w := th.do(http.MethodGet, segment+"?token="+token, nil); w.Code != http.StatusForbidden {
+		t.Errorf("segment outside the token's renditions: %d", w.Code)
+	}
+	_, token, _ = descriptor("?renditions=720p")
+	if w := th.do(http.MethodGet, segment+"?token="+token, nil); w.Code != http.StatusOK {
+		t.Errorf("segment inside the token's renditions: %d", w.Code)
+	}
+	if code, _, _ := descriptor("?renditions=../x"); code != http.StatusBadRequest {
+		t.Errorf("malformed rendition list: %d", code)
+	}
+}
+
+func mustToken(s *auth.URLSigner) string {
+	raw, _ := s.Issue("u1", "", nil)
+	return raw
+}
@@ -0,0 +1,96 @@
+package playback
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/streamhive/playback-service/internal/cache"
+)
+
+// POST /admin/cache/purge/:uploadId
+//
+// Drops everything cached for an upload (Redis, in-process tiers and parsed
+// playlists) and tells the other pods to do the same. Requires the
+// X-Admin-Token header to match the configured admin token. Answers 503
+// while Redis is unreachable so the purge can be retried.
+func (h *Handler) PurgeCache(c *gin.Context) {
+	if !h.adminAllowed(c) {
+		return
+	}
+	uploadID := c.Param("uploadId")
+	ctx := c.Request.Context()
+	n, err := h.purge(ctx, uploadID)
+	if errors.Is(err, cache.ErrUnavailable) {
+		h.log.Errorw("cache purge", "uploadId", uploadID, "err", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "cache unavailable; retry the purge"})
+		return
+	}
+	if err != nil {
+		h.log.Errorw("cache purge", "uploadId", uploadID, "err", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "purge failed"})
+		return
+	}
+	broadcast := false
+	if b, ok := h.cache.(cache.Broadcaster); ok {
+		if err := b.PublishPurge(ctx, uploadID); err != nil {
+			h.log.Warnw("purge broadcast", "uploadId", uploadID, "err", err)
+		} else {
+			broadcast = true
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"uploadId": uploadID, "purged": n, "broadcast": broadcast})
+}
+
+func (h *Handler) adminAllowed(c *gin.Context) bool {
+	if h.adminToken == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin API disabled"})
+		return false
+	}
+	got := c.GetHeader("X-Admin-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(h.adminToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+		return false
+	}
+	return true
+}
+
+// purge removes an upload from the shared cache and from this pod's
+// in-process state.
+func (h *Handler) purge(ctx context.Context, uploadID string) (int, error) {
+	if set, ok := h.renditionCache.Get(uploadID); ok {
+		for _, r := range set.Renditions {
+			h.playlists.Delete(r.PlaylistPath)
+		}
+	}
+	h.renditionCache.Delete(uploadID)
+	h.forgetVideo(uploadID)
+	return h.cache.Purge(ctx, uploadID)
+}
+
+// listenPurges applies purge requests published by other pods or by the
+// catalog service until ctx is done.
+func (h *Handler) listenPurges(ctx context.Context) {
+	b, ok := h.cache.(cache.Broadcaster)
+	if !ok {
+		return
+	}
+	b.SubscribePurges(ctx, func(uploadID string) {
+		pctx, cancel := context.WithTimeout(ctx, h.fetchTimeout)
+		defer cancel()
+		if _, err := h.purge(pctx, uploadID); err != nil {
+			h.log.Warnw("cache purge", "uploadId", uploadID, "err", err)
+		}
+	})
+}
+
+// Close stops background work (purge listener, prefetch workers) and then
+// releases the cache. Call it after the HTTP server has shut down.
+func (h *Handler) Close() error {
+	h.stop()
+	h.prefetch.stop()
+	return h.cache.Close()
+}
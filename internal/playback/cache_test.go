@@ -0,0 +1,190 @@
+package playback
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/config"
+	"github.com/streamhive/playback-service/internal/models"
+	"github.com/streamhive/playback-service/internal/storage"
+)
+
+const (
+	testUpload     = "u1"
+	testAdminToken = "admin-secret"
+	testSegment    = "videos/u1/720p/seg0.ts"
+)
+
+// testHandler serves one public video from an in-memory blob store through
+// an in-memory cache.
+type testHandler struct {
+	h      *Handler
+	store  *storage.MemoryStore
+	cache  *cache.MemoryCache
+	router *gin.Engine
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	t.Helper()
+	return newTestHandlerWith(t, nil)
+}
+
+// newTestHandlerWith is newTestHandler with the handler's cache built by
+// wrapping the in-memory one.
+func newTestHandlerWith(t *testing.T, wrap func(*cache.MemoryCache) cache.Cache) *testHandler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	t.Setenv("PLAYBACK_BLOB_BACKEND", "memory")
+	t.Setenv("CACHE_BACKEND", "memory")
+	t.Setenv("PLAYBACK_ADMIN_TOKEN", testAdminToken)
+	t.Setenv("PLAYBACK_SECRETS_DIR", t.TempDir())
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	mem := cache.NewMemoryCache(1<<20, 1<<20, 0)
+	var c cache.Cache = mem
+	if wrap != nil {
+		c = wrap(mem)
+	}
+	h, err := NewHandler(nil, zap.NewNop().Sugar(), cfg, WithCache(c))
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+
+	store, ok := h.store.(*storage.MemoryStore)
+	if !ok {
+		t.Fatalf("store is %T, want *storage.MemoryStore", h.store)
+	}
+	store.Put("videos/u1/master.m3u8", []byte("#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000000\n720p/index.m3u8\n"), "application/vnd.apple.mpegurl")
+	store.Put("videos/u1/720p/index.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg0.ts\n#EXT-X-ENDLIST\n"), "application/vnd.apple.mpegurl")
+	store.Put(testSegment, []byte("0123456789"), "video/mp2t")
+
+	r := gin.New()
+	r.GET("/playback/videos/:uploadId/:rendition/*file", h.GetRenditionFile)
+	r.POST("/admin/cache/purge/:uploadId", h.PurgeCache)
+	th := &testHandler{h: h, store: store, cache: mem, router: r}
+	th.loadVideo()
+	return th
+}
+
+// loadVideo stands in for the catalog row, which has no database here.
+func (th *testHandler) loadVideo() {
+	th.h.videos.entries.Set(testUpload, &models.Video{UploadID: testUpload, HLSMasterURL: "videos/u1/master.m3u8"})
+}
+
+func (th *testHandler) do(method, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	th.router.ServeHTTP(w, req)
+	return w
+}
+
+func (th *testHandler) segmentCached(t *testing.T) bool {
+	t.Helper()
+	n, _, err := th.cache.Stat(context.Background(), cache.GenerateKey("segment", testUpload, testSegment))
+	if err != nil {
+		t.Fatalf("cache stat: %v", err)
+	}
+	return n > 0
+}
+
+func TestSegmentCache(t *testing.T) {
+	th := newTestHandler(t)
+	const url = "/playback/videos/u1/720p/seg0.ts"
+
+	// Miss: served from the blob store and stored in the cache.
+	w := th.do(http.MethodGet, url, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("miss: %d %q", w.Code, w.Body.String())
+	}
+	if !th.segmentCached(t) {
+		t.Fatal("segment not cached after a miss")
+	}
+
+	// Hit: the cached copy wins over the (changed) blob.
+	th.store.Put(testSegment, []byte("abcdefghij"), "video/mp2t")
+	w = th.do(http.MethodGet, url, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("hit: %d %q", w.Code, w.Body.String())
+	}
+
+	// Range: sliced from the cached copy.
+	w = th.do(http.MethodGet, url, http.Header{"Range": {"bytes=2-5"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Fatalf("range: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("Content-Range = %q", got)
+	}
+	w = th.do(http.MethodGet, url, http.Header{"Range": {"bytes=-3"}})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "789" {
+		t.Fatalf("suffix range: %d %q", w.Code, w.Body.String())
+	}
+
+	// Purge: the next request goes back to the blob store.
+	w = th.do(http.MethodPost, "/admin/cache/purge/u1", http.Header{"X-Admin-Token": {testAdminToken}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", w.Code, w.Body.String())
+	}
+	if th.segmentCached(t) {
+		t.Fatal("segment still cached after purge")
+	}
+	th.loadVideo()
+	w = th.do(http.MethodGet, url, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "abcdefghij" {
+		t.Fatalf("after purge: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRangeMiss(t *testing.T) {
+	th := newTestHandler(t)
+	w := th.do(http.MethodGet, "/playback/videos/u1/720p/seg0.ts", http.Header{"Range": {"bytes=4-"}})
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), []byte("456789")) {
+		t.Fatalf("range miss: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 4-9/10" {
+		t.Errorf("Content-Range = %q", got)
+	}
+}
+
+func TestPurgeRequiresToken(t *testing.T) {
+	th := newTestHandler(t)
+	th.do(http.MethodGet, "/playback/videos/u1/720p/seg0.ts", nil)
+	w := th.do(http.MethodPost, "/admin/cache/purge/u1", http.Header{"X-Admin-Token": {"wrong"}})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("purge with bad token: %d", w.Code)
+	}
+	if !th.segmentCached(t) {
+		t.Fatal("segment purged without a valid token")
+	}
+}
+
+// downCache is a cache whose shared tier is unreachable.
+type downCache struct {
+	*cache.MemoryCache
+}
+
+func (c downCache) Purge(ctx context.Context, uploadID string) (int, error) {
+	n, _ := c.MemoryCache.Purge(ctx, uploadID)
+	return n, cache.ErrUnavailable
+}
+
+func TestPurgeCacheUnavailable(t *testing.T) {
+	th := newTestHandlerWith(t, func(mem *cache.MemoryCache) cache.Cache { return downCache{mem} })
+	w := th.do(http.MethodPost, "/admin/cache/purge/u1", http.Header{"X-Admin-Token": {testAdminToken}})
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("purge with cache down: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,206 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/storage"
+)
+
+// errTooLarge tells callers to stream an object themselves instead of
+// sharing a buffered copy.
+var errTooLarge = errors.New("object too large to coalesce")
+
+// shared runs fn at most once per key at a time; concurrent callers with the
+// same key wait for and share its result. fn is detached from the starting
+// caller's cancellation so one viewer going away does not fail the others,
+// while every caller still stops waiting when its own context is done.
+func (h *Handler) shared(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	ch := h.flight.DoChan(key, func() (interface{}, error) {
+		fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.fetchTimeout)
+		defer cancel()
+		return fn(fctx)
+	})
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// objectFlights tracks in-flight downloads of cacheable blobs by path.
+type objectFlights struct {
+	mu      sync.Mutex
+	flights map[string]*objectFlight
+}
+
+// objectFlight is one download being read into memory. ready is closed once
+// the blob is open (info is set) or could not be opened (err is set); after
+// that data only grows until ended is set, and changed is closed and
+// replaced whenever either happens.
+type objectFlight struct {
+	ready chan struct{}
+	info  *storage.BlobInfo
+
+	mu      sync.Mutex
+	data    []byte
+	ended   bool
+	err     error
+	changed chan struct{}
+}
+
+// openShared opens a blob for reading, downloading it once for all
+// concurrent callers. The body is read into memory by a background fill that
+// does not wait for any caller; every caller, the first one included, reads
+// from that buffer as it grows, so a slow viewer neither holds up the others
+// nor the download. The complete copy is cached. Objects larger than
+// maxCacheObject are not buffered: the first caller streams them and the
+// others get errTooLarge as soon as the size is known, so they can open
+// their own stream.
+//
+// The download is detached from the callers' cancellation and bounded by
+// fetchTimeout; each reader stops waiting when its own context is done.
+func (h *Handler) openShared(ctx context.Context, uploadID, blobPath, cacheKey string) (io.ReadCloser, *storage.BlobInfo, error) {
+	fl := &h.objects
+	fl.mu.Lock()
+	if f, ok := fl.flights[blobPath]; ok {
+		fl.mu.Unlock()
+		select {
+		case <-f.ready:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if f.info == nil {
+			return nil, nil, f.err
+		}
+		return &flightReader{ctx: ctx, f: f}, f.info, nil
+	}
+	if fl.flights == nil {
+		fl.flights = make(map[string]*objectFlight)
+	}
+	f := &objectFlight{ready: make(chan struct{}), changed: make(chan struct{})}
+	fl.flights[blobPath] = f
+	fl.mu.Unlock()
+
+	forget := func() {
+		fl.mu.Lock()
+		delete(fl.flights, blobPath)
+		fl.mu.Unlock()
+	}
+
+	fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	body, info, err := h.openBlob(fctx, blobPath, 0, 0)
+	if err != nil {
+		cancel()
+		forget()
+		f.err = err
+		close(f.ready)
+		return nil, nil, err
+	}
+	if info.Size > h.maxCacheObject {
+		forget()
+		f.err = errTooLarge
+		close(f.ready)
+		stop := context.AfterFunc(ctx, cancel)
+		return &cancelOnClose{ReadCloser: body, cancel: func() { stop(); cancel() }}, info, nil
+	}
+	f.info = info
+	f.data = make([]byte, 0, info.Size)
+	close(f.ready)
+
+	go func() {
+		defer cancel()
+		timer := time.AfterFunc(h.fetchTimeout, cancel)
+		err := f.fill(body, h.maxCacheObject)
+		timer.Stop()
+		body.Close()
+		if err == nil {
+			meta := cache.Meta{UploadID: uploadID, ETag: info.ETag, LastModified: info.LastModified}
+			if err := h.cache.Set(fctx, cacheKey, f.data, meta); err != nil {
+				h.log.Warnw("cache set error", "err", err)
+			}
+		}
+		forget()
+		f.end(err)
+	}()
+	return &flightReader{ctx: ctx, f: f}, info, nil
+}
+
+// fill reads body into the flight's buffer, failing with errTooLarge past
+// limit bytes.
+func (f *objectFlight) fill(body io.Reader, limit int64) error {
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			f.mu.Lock()
+			f.data = append(f.data, buf[:n]...)
+			size := int64(len(f.data))
+			f.notify()
+			f.mu.Unlock()
+			if size > limit {
+				return errTooLarge
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// end marks the download finished, with err if it was cut short.
+func (f *objectFlight) end(err error) {
+	f.mu.Lock()
+	f.ended, f.err = true, err
+	f.notify()
+	f.mu.Unlock()
+}
+
+// notify wakes the readers waiting for more data. f.mu must be held.
+func (f *objectFlight) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// flightReader reads a flight's buffer from the start, waiting for the fill
+// when it has caught up with it.
+type flightReader struct {
+	ctx context.Context
+	f   *objectFlight
+	off int
+}
+
+func (r *flightReader) Read(p []byte) (int, error) {
+	for {
+		r.f.mu.Lock()
+		if r.off < len(r.f.data) {
+			n := copy(p, r.f.data[r.off:])
+			r.f.mu.Unlock()
+			r.off += n
+			return n, nil
+		}
+		ended, err, changed := r.f.ended, r.f.err, r.f.changed
+		r.f.mu.Unlock()
+		if ended {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		select {
+		case <-changed:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+}
+
+func (r *flightReader) Close() error { return nil }
@@ -0,0 +1,116 @@
+package playback
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/storage"
+)
+
+// gatedStore counts blob opens and holds every body until release is closed.
+type gatedStore struct {
+	storage.BlobStore
+	opens   atomic.Int32
+	release chan struct{}
+}
+
+func (s *gatedStore) GetRange(ctx context.Context, path string, offset, count int64) (io.ReadCloser, *storage.BlobInfo, error) {
+	s.opens.Add(1)
+	body, info, err := s.BlobStore.GetRange(ctx, path, offset, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &gatedBody{ReadCloser: body, release: s.release}, info, nil
+}
+
+type gatedBody struct {
+	io.ReadCloser
+	release chan struct{}
+}
+
+func (b *gatedBody) Read(p []byte) (int, error) {
+	<-b.release
+	return b.ReadCloser.Read(p)
+}
+
+func TestOpenSharedSlowFirstReader(t *testing.T) {
+	th := newTestHandler(t)
+	store := &gatedStore{BlobStore: th.store, release: make(chan struct{})}
+	th.h.store = store
+	th.h.fetchTimeout = 20 * time.Millisecond
+	ctx := context.Background()
+	key := cache.GenerateKey("segment", testUpload, testSegment)
+
+	// The first viewer opens the segment and then does not read.
+	first, _, err := th.h.openShared(ctx, testUpload, testSegment, key)
+	if err != nil {
+		t.Fatalf("first open: %v", err)
+	}
+	defer first.Close()
+
+	second, info, err := th.h.openShared(ctx, testUpload, testSegment, key)
+	if err != nil {
+		t.Fatalf("second open: %v", err)
+	}
+	defer second.Close()
+	if info.Size != 10 {
+		t.Errorf("size = %d, want 10", info.Size)
+	}
+	close(store.release)
+
+	got := make(chan string, 1)
+	go func() {
+		data, err := io.ReadAll(second)
+		if err != nil {
+			t.Errorf("second read: %v", err)
+		}
+		got <- string(data)
+	}()
+	select {
+	case data := <-got:
+		if data != "0123456789" {
+			t.Fatalf("second read %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second viewer waited for the first one")
+	}
+
+	// Well past fetchTimeout, the first viewer still gets the whole body.
+	time.Sleep(3 * th.h.fetchTimeout)
+	data, err := io.ReadAll(first)
+	if err != nil || string(data) != "0123456789" {
+		t.Fatalf("first read: %q, %v", data, err)
+	}
+	if n := store.opens.Load(); n != 1 {
+		t.Errorf("blob opened %d times, want 1", n)
+	}
+	if !th.segmentCached(t) {
+		t.Error("segment not cached after the shared download")
+	}
+}
+
+func TestOpenSharedTooLarge(t *testing.T) {
+	th := newTestHandler(t)
+	store := &gatedStore{BlobStore: th.store, release: make(chan struct{})}
+	close(store.release)
+	th.h.store = store
+	th.h.maxCacheObject = 4
+	key := cache.GenerateKey("segment", testUpload, testSegment)
+
+	body, _, err := th.h.openShared(context.Background(), testUpload, testSegment, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil || string(data) != "0123456789" {
+		t.Fatalf("read: %q, %v", data, err)
+	}
+	if th.segmentCached(t) {
+		t.Error("object larger than maxCacheObject was cached")
+	}
+}
@@ -0,0 +1,67 @@
+package playback
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notModified sets the ETag and Last-Modified validators and, when the
+// request's If-None-Match or If-Modified-Since matches them, answers 304 and
+// reports true. If-None-Match takes precedence, as in RFC 9110.
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	etag = quoteETag(etag)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !modified.IsZero() {
+		c.Header("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if m := c.Request.Method; m != http.MethodGet && m != http.MethodHead {
+		return false
+	}
+
+	match := false
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		match = etag != "" && etagMatches(inm, etag)
+	} else if ims := c.GetHeader("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			match = !modified.Truncate(time.Second).After(t)
+		}
+	}
+	if match {
+		c.Status(http.StatusNotModified)
+	}
+	return match
+}
+
+// etagMatches applies the weak comparison used for If-None-Match.
+func etagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteETag turns a bare entity tag into its quoted header form.
+func quoteETag(tag string) string {
+	if tag == "" || strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
+
+// contentETag derives a strong ETag from a generated body such as a
+// rewritten playlist.
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
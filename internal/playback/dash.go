@@ -0,0 +1,106 @@
+package playback
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+	"github.com/streamhive/playback-service/internal/dash"
+	"github.com/streamhive/playback-service/internal/hls"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// GET /playback/videos/:uploadId/manifest.mpd
+//
+// Builds a DASH manifest from the HLS renditions of CMAF content. Segments
+// are served by the same routes as HLS.
+func (h *Handler) GetDASH(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	var v models.Video
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	grant, ok := h.authorizeMedia(c, &v, "")
+	if !ok {
+		return
+	}
+	if v.HLSMasterURL == "" {
+		c.String(http.StatusBadRequest, "master not ready")
+		return
+	}
+	ctx := c.Request.Context()
+	set, err := h.renditions(ctx, &v)
+	if err != nil {
+		h.log.Errorw("master load", "err", err)
+		playlistError(c, err)
+		return
+	}
+
+	var tracks []dash.Track
+	for _, variant := range set.Master.Variants {
+		name := set.nameOf(variant.URI)
+		if _, ok := set.lookup(name); ok && grant.allowsRendition(name) {
+			tracks = append(tracks, dash.Track{Name: name, Variant: variant})
+		}
+	}
+	for _, media := range set.Master.Media {
+		if media.URI == "" || media.Type != "AUDIO" {
+			continue
+		}
+		name := set.nameOf(media.URI)
+		if _, ok := set.lookup(name); !ok || !grant.allowsRendition(name) {
+			continue
+		}
+		t := dash.Track{Name: name, Media: media}
+		// EXT-X-MEDIA carries no codecs or bitrate; take them from the
+		// first variant that uses this audio group.
+		for _, variant := range set.Master.Variants {
+			if variant.Audio == media.GroupID {
+				t.Codecs = dash.AudioCodecs(variant.Codecs)
+				break
+			}
+		}
+		tracks = append(tracks, t)
+	}
+
+	for i := range tracks {
+		r, _ := set.lookup(tracks[i].Name)
+		// Shared and read-only; Build does not modify it.
+		if tracks[i].Playlist, err = h.mediaPlaylist(ctx, r.PlaylistPath); err != nil {
+			h.log.Errorw("variant load", "path", r.PlaylistPath, "err", err)
+			var perr *hls.ParseError
+			if errors.As(err, &perr) {
+				c.String(http.StatusBadGateway, "invalid variant playlist")
+				return
+			}
+			blobError(c, err)
+			return
+		}
+	}
+
+	mpd, err := dash.Build(tracks, dash.Options{Query: grant.query()})
+	if errors.Is(err, dash.ErrUnsupported) {
+		c.String(http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		h.log.Errorw("mpd build", "uploadId", uploadID, "err", err)
+		c.String(http.StatusInternalServerError, "manifest error")
+		return
+	}
+	out, err := mpd.Encode()
+	if err != nil {
+		h.log.Errorw("mpd encode", "uploadId", uploadID, "err", err)
+		c.String(http.StatusInternalServerError, "manifest error")
+		return
+	}
+	h.cacheHeaders(c, cachepolicy.Manifest, &v, grant.query() != "", h.live(ctx, set))
+	if notModified(c, contentETag(out), time.Time{}) {
+		return
+	}
+	c.Data(http.StatusOK, "application/dash+xml", out)
+}
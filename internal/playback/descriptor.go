@@ -0,0 +1,288 @@
+package playback
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// descriptorVersion is bumped on incompatible changes to the descriptor.
+const descriptorVersion = 1
+
+// Availability of a video's media as reported in the descriptor.
+const (
+	statusReady       = "ready"
+	statusProcessing  = "processing"
+	statusUnavailable = "unavailable"
+)
+
+// descriptor is everything a player needs to start playback of one video.
+type descriptor struct {
+	Version     int                `json:"version"`
+	UploadID    string             `json:"uploadId"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Tags        models.StringArray `json:"tags"`
+	Category    string             `json:"category"`
+	Duration    float64            `json:"duration"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	// Status is ready, processing (no master playlist yet) or unavailable
+	// (the master playlist could not be loaded).
+	Status string `json:"status"`
+	Live   bool   `json:"live"`
+
+	HLS        *descriptorHLS        `json:"hls,omitempty"`
+	DASH       *descriptorDASH       `json:"dash,omitempty"`
+	Thumbnail  string                `json:"thumbnail,omitempty"`
+	Renditions []descriptorRendition `json:"renditions"`
+	Access     descriptorAccess      `json:"access"`
+
+	// Token is the signed playback token already appended to the URLs above;
+	// players that build their own URLs add it as ?token=.
+	Token          string     `json:"token,omitempty"`
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt,omitempty"`
+}
+
+type descriptorHLS struct {
+	Master string `json:"master"`
+}
+
+type descriptorDASH struct {
+	Manifest string `json:"manifest"`
+}
+
+// descriptorRendition is one entry of the rendition ladder: a variant stream
+// or an alternate audio/subtitle rendition.
+type descriptorRendition struct {
+	Name             string  `json:"name"`
+	Type             string  `json:"type"`
+	Resolution       string  `json:"resolution,omitempty"`
+	Width            int     `json:"width,omitempty"`
+	Height           int     `json:"height,omitempty"`
+	Bandwidth        int64   `json:"bandwidth,omitempty"`
+	AverageBandwidth int64   `json:"averageBandwidth,omitempty"`
+	Codecs           string  `json:"codecs,omitempty"`
+	FrameRate        float64 `json:"frameRate,omitempty"`
+	Language         string  `json:"language,omitempty"`
+	Label            string  `json:"label,omitempty"`
+	Default          bool    `json:"default,omitempty"`
+}
+
+// descriptorAccess tells the player what media requests need.
+type descriptorAccess struct {
+	Private bool `json:"private"`
+	// RequiresAuth is set when media requests need a bearer token or the
+	// signed token from this response.
+	RequiresAuth bool `json:"requiresAuth"`
+	// SignedURLs is set when the URLs carry a ?token= that expires.
+	SignedURLs bool `json:"signedUrls"`
+}
+
+// GET /playback/videos/:uploadId[?renditions=360p,720p]
+//
+// With signed URLs enabled, ?renditions= narrows the issued token (and the
+// listed ladder) to the named renditions.
+func (h *Handler) GetDescriptor(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	var v models.Video
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if !h.authorize(c, &v) {
+		return
+	}
+	d := descriptor{
+		Version:     descriptorVersion,
+		UploadID:    v.UploadID,
+		Title:       v.Title,
+		Description: v.Description,
+		Tags:        v.Tags,
+		Category:    v.Category,
+		Duration:    v.Duration,
+		CreatedAt:   v.CreatedAt.UTC(),
+		Renditions:  []descriptorRendition{},
+		Access: descriptorAccess{
+			Private:      v.IsPrivate,
+			RequiresAuth: v.IsPrivate || h.requireSigned,
+			SignedURLs:   h.signer != nil,
+		},
+	}
+	renditions, ok := requestedRenditions(c)
+	if !ok {
+		return
+	}
+	grant := &mediaGrant{}
+	if h.signer != nil {
+		// Players cannot attach headers to every segment request, so hand
+		// out a signed token to append to playback URLs instead.
+		raw, tok := h.signer.Issue(v.UploadID, c.ClientIP(), renditions)
+		exp := tok.ExpiresAt().UTC()
+		d.Token, d.TokenExpiresAt = raw, &exp
+		grant = &mediaGrant{raw: raw, token: tok}
+	} else if len(renditions) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rendition restrictions need signed playback URLs"})
+		return
+	}
+	query := grant.query()
+	origin, trusted := h.baseURL(c)
+	base := origin + "/playback/videos/" + url.PathEscape(v.UploadID)
+	if v.ThumbnailURL != "" {
+		d.Thumbnail = withQuery(base+"/thumbnail.jpg", query)
+	}
+
+	d.Status = statusProcessing
+	if v.HLSMasterURL != "" {
+		h.describeMedia(c.Request.Context(), &v, &d, base, grant)
+	}
+	// URLs built from an untrusted Host header must not reach shared caches.
+	h.cacheHeaders(c, cachepolicy.Descriptor, &v, h.signer != nil || !trusted, d.Live)
+	c.JSON(http.StatusOK, d)
+}
+
+// requestedRenditions parses the optional ?renditions= list with which a
+// caller narrows its playback token to part of the ladder, e.g. for an embed
+// that must not pull the top renditions. Malformed names are answered with
+// 400 and false.
+func requestedRenditions(c *gin.Context) ([]string, bool) {
+	q := c.Query("renditions")
+	if q == "" {
+		return nil, true
+	}
+	var names []string
+	for _, name := range strings.Split(q, ",") {
+		name = strings.TrimSpace(name)
+		if !renditionName.MatchString(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rendition " + strconv.Quote(name)})
+			return nil, false
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	// Sorted so the same request in a token window gets the same token.
+	slices.Sort(names)
+	return names, true
+}
+
+// describeMedia fills in the playback URLs and the part of the rendition
+// ladder the grant covers from the video's master playlist.
+func (h *Handler) describeMedia(ctx context.Context, v *models.Video, d *descriptor, base string, grant *mediaGrant) {
+	query := grant.query()
+	set, err := h.renditions(ctx, v)
+	if err != nil {
+		h.log.Warnw("descriptor renditions", "uploadId", v.UploadID, "err", err)
+		d.Status = statusUnavailable
+		return
+	}
+	d.Status = statusReady
+	d.HLS = &descriptorHLS{Master: withQuery(base+"/master.m3u8", query)}
+
+	// Liveness and DASH support are judged by the first variant whose
+	// media playlist loads.
+	probed := false
+	for _, variant := range set.Master.Variants {
+		name := set.nameOf(variant.URI)
+		r, ok := set.lookup(name)
+		if !ok {
+			continue
+		}
+		if !probed {
+			// DASH is only offered for finished CMAF (fMP4) content.
+			if p, err := h.mediaPlaylist(ctx, r.PlaylistPath); err == nil {
+				probed = true
+				d.Live = !p.IsVOD()
+				if p.IsVOD() && len(p.Segments) > 0 && p.Segments[0].Map != nil {
+					d.DASH = &descriptorDASH{Manifest: withQuery(base+"/manifest.mpd", query)}
+				}
+			}
+		}
+		if !grant.allowsRendition(name) {
+			continue
+		}
+		kind := "video"
+		if variant.AudioOnly() {
+			kind = "audio"
+		}
+		d.Renditions = append(d.Renditions, descriptorRendition{
+			Name:             name,
+			Type:             kind,
+			Resolution:       variant.Resolution(),
+			Width:            variant.Width,
+			Height:           variant.Height,
+			Bandwidth:        variant.Bandwidth,
+			AverageBandwidth: variant.AverageBandwidth,
+			Codecs:           variant.Codecs,
+			FrameRate:        variant.FrameRate,
+		})
+	}
+	for _, media := range set.Master.Media {
+		if media.URI == "" {
+			continue
+		}
+		name := set.nameOf(media.URI)
+		if _, ok := set.lookup(name); !ok || !grant.allowsRendition(name) {
+			continue
+		}
+		d.Renditions = append(d.Renditions, descriptorRendition{
+			Name:     name,
+			Type:     strings.ToLower(media.Type),
+			Language: media.Language,
+			Label:    media.Name,
+			Default:  media.Default,
+		})
+	}
+}
+
+// baseURL is the external scheme and host playback URLs are built on:
+// server.public_url when set, otherwise taken from the request. The
+// X-Forwarded-* headers are only believed from a trusted proxy. trusted is
+// false when the result came from headers any client can set, so the
+// response must not be cached for others.
+func (h *Handler) baseURL(c *gin.Context) (base string, trusted bool) {
+	if h.publicURL != "" {
+		return h.publicURL, true
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if !h.fromTrustedProxy(c) {
+		return scheme + "://" + host, false
+	}
+	if p := c.GetHeader("X-Forwarded-Proto"); p == "http" || p == "https" {
+		scheme = p
+	}
+	if fh := c.GetHeader("X-Forwarded-Host"); fh != "" {
+		host, _, _ = strings.Cut(fh, ",")
+		host = strings.TrimSpace(host)
+	}
+	return scheme + "://" + host, true
+}
+
+// fromTrustedProxy reports whether the request's peer is one of the
+// configured trusted proxies.
+func (h *Handler) fromTrustedProxy(c *gin.Context) bool {
+	addr, err := netip.ParseAddr(c.RemoteIP())
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range h.trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package playback
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+func TestDescriptorSkipsUnresolvedFirstVariant(t *testing.T) {
+	tests := []struct {
+		name  string
+		first string // first variant, which does not resolve
+		media string // media playlist of the variant that does
+		live  bool
+		dash  bool
+	}{
+		{"missing playlist, fMP4 VOD", "missing/index.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\nseg0.m4s\n#EXT-X-ENDLIST\n", false, true},
+		{"unsupported name, live", "bad%20name/index.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:4\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\nseg0.m4s\n", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			th.store.Put("videos/u4/master.m3u8", []byte("#EXTM3U\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=5000000\n"+tt.first+"\n"+
+				"#EXT-X-STREAM-INF:BANDWIDTH=2000000\n720p/index.m3u8\n"), "application/vnd.apple.mpegurl")
+			th.store.Put("videos/u4/720p/index.m3u8", []byte(tt.media), "application/vnd.apple.mpegurl")
+			th.h.videos.entries.Set("u4", &models.Video{UploadID: "u4", HLSMasterURL: "videos/u4/master.m3u8"})
+			th.authRoutes()
+
+			w := th.do(http.MethodGet, "/playback/videos/u4", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("descriptor: %d %s", w.Code, w.Body.String())
+			}
+			var d descriptor
+			if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if d.Live != tt.live || (d.DASH != nil) != tt.dash {
+				t.Errorf("live = %v, dash = %v; want %v, %v", d.Live, d.DASH != nil, tt.live, tt.dash)
+			}
+		})
+	}
+}
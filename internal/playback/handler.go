@@ -2,469 +2,483 @@ package playback
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
+	"net/netip"
 	"os"
 	"path"
-	"regexp"
 	"strings"
-    "time"
-	"strconv"
+	"sync/atomic"
+	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
-	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 	"github.com/gin-gonic/gin"
 	"github.com/sony/gobreaker"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 	"gorm.io/gorm"
 
+	"github.com/streamhive/playback-service/internal/auth"
 	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+	"github.com/streamhive/playback-service/internal/config"
+	"github.com/streamhive/playback-service/internal/hls"
+	"github.com/streamhive/playback-service/internal/metrics"
 	"github.com/streamhive/playback-service/internal/models"
+	"github.com/streamhive/playback-service/internal/storage"
 )
 
-// Helper function to read secret from file or fallback to environment variable
-func getSecret(filePath, envVar string) string {
-	if data, err := ioutil.ReadFile(filePath); err == nil {
-		return strings.TrimSpace(string(data))
-	}
-	return os.Getenv(envVar)
+type Handler struct {
+	db            *gorm.DB
+	log           *zap.SugaredLogger
+	store         storage.BlobStore
+	containerName string
+	cache         cache.Cache
+	breaker       *gobreaker.CircuitBreaker
+	// maxCacheObject caps the size of objects teed into the cache.
+	maxCacheObject int64
+	// Blob download policy: per-attempt timeout and number of retries.
+	attemptTimeout time.Duration
+	retries        int
+	// videos caches video rows by upload ID.
+	videos *videoCache
+	// renditionCache holds the parsed master playlist per upload ID.
+	renditionCache *ttlMap[*renditionSet]
+	// verifier validates bearer tokens; nil when auth is not configured.
+	verifier *auth.Verifier
+	// signer issues and checks signed playback URLs; nil when disabled.
+	signer        *auth.URLSigner
+	requireSigned bool
+	// flight coalesces concurrent video, playlist and rendition loads per key.
+	flight       singleflight.Group
+	fetchTimeout time.Duration
+	// objects shares in-flight downloads of cacheable blobs.
+	objects objectFlights
+	// prefetch warms the cache for the segments after the requested one.
+	prefetch *prefetcher
+	// playlists holds parsed media playlists by blob path.
+	playlists *ttlMap[*hls.MediaPlaylist]
+	// cachePolicy decides Cache-Control and CDN headers per response.
+	cachePolicy *cachepolicy.Policy
+	// publicURL is the external base URL of the service, e.g.
+	// https://media.example.com; empty derives it from each request.
+	publicURL string
+	// trustedProxies may set the X-Forwarded-* headers used for it.
+	trustedProxies []netip.Prefix
+	// adminToken guards the admin routes; empty disables them.
+	adminToken string
+	// stop cancels background goroutines (purge listener).
+	stop context.CancelFunc
+	// draining is set on shutdown so /readyz fails.
+	draining     atomic.Bool
+	readyTimeout time.Duration
+	// blobProbe is the blob /readyz stats; empty checks the container.
+	blobProbe string
 }
 
-type Handler struct {
-	db              *gorm.DB
-	log             *zap.SugaredLogger
-	client          *http.Client
-	containerClient *container.Client
-	account         string
-	containerName   string
-	cache           *cache.CacheService
-	breaker         *gobreaker.CircuitBreaker
+// Option customises a Handler built by NewHandler.
+type Option func(*options)
+
+type options struct {
+	cache cache.Cache
 }
 
-func NewHandler(db *gorm.DB, log *zap.SugaredLogger) *Handler {
-	// Initialize Azure client (supports connection string or account+key)
-	ctx := context.Background()
-	var cc *container.Client
-	account := getSecret("/mnt/secrets-store/azure-storage-account", "AZURE_STORAGE_ACCOUNT")
-	containerName := getSecret("/mnt/secrets-store/azure-storage-raw-container", "AZURE_BLOB_CONTAINER")
-	if conn := getSecret("/mnt/secrets-store/azure-storage-connection-string", "AZURE_STORAGE_CONNECTION_STRING"); conn != "" && containerName != "" {
-		c, err := container.NewClientFromConnectionString(conn, containerName, nil)
-		if err == nil {
-			cc = c
-		} else {
-			log.Errorw("azure conn string", "err", err)
-		}
-	} else if account != "" && containerName != "" {
-		cred, err := azblob.NewSharedKeyCredential(account, getSecret("/mnt/secrets-store/azure-storage-key", "AZURE_STORAGE_KEY"))
-		if err == nil {
-			url := "https://" + account + ".blob.core.windows.net/" + containerName
-			c, e2 := container.NewClientWithSharedKeyCredential(url, cred, nil)
-			if e2 == nil {
-				cc = c
-			} else {
-				log.Errorw("azure client", "err", e2)
-			}
-		} else {
-			log.Errorw("azure credential", "err", err)
-		}
-	}
-	if cc == nil {
-		log.Warn("azure container client not initialized; playback will fail for private blobs")
+// WithCache makes the handler use c instead of the cache selected from the
+// environment, e.g. an in-memory cache in tests.
+func WithCache(c cache.Cache) Option {
+	return func(o *options) { o.cache = c }
+}
+
+// NewHandler builds the playback handler from a validated configuration. It
+// fails if the blob backend cannot be initialized.
+func NewHandler(db *gorm.DB, log *zap.SugaredLogger, cfg *config.Config, opts ...Option) (*Handler, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	// Initialize cache service
-	cacheService, err := cache.NewCacheService(log)
+	// Blob backend (azure|local|memory)
+	bc := cfg.Blob
+	storeCfg := storage.Config{
+		Backend:               bc.Backend,
+		LocalDir:              bc.LocalDir,
+		AzureAccount:          bc.AzureAccount,
+		AzureContainer:        bc.AzureContainer,
+		AzureConnectionString: bc.AzureConnectionString,
+		AzureKey:              bc.AzureKey,
+	}
+	store, err := storage.New(storeCfg)
 	if err != nil {
-		log.Errorw("failed to initialize cache service", "err", err)
-		cacheService = nil // Continue without cache
+		return nil, fmt.Errorf("blob store: %w", err)
 	}
 
-	_ = ctx // reserved
-
-	// HTTP client with timeout (env: PLAYBACK_HTTP_TIMEOUT_MS)
-	httpTimeout := 5 * time.Second
-	if v := os.Getenv("PLAYBACK_HTTP_TIMEOUT_MS"); v != "" {
-		if d, err := time.ParseDuration(v + "ms"); err == nil {
-			httpTimeout = d
-		}
+	// Object cache (redis|memory|none)
+	cacheService := o.cache
+	if cacheService == nil {
+		cacheService = cache.New(cfg.Cache, log)
 	}
-	httpClient := &http.Client{Timeout: httpTimeout}
+	metrics.SetCacheStats(cacheService.Stats)
 
-	// Circuit breaker for Azure blob downloads
-	cbTimeout := 10 * time.Second
-	if v := os.Getenv("PLAYBACK_CB_RESET_MS"); v != "" {
-		if d, err := time.ParseDuration(v + "ms"); err == nil {
-			cbTimeout = d
-		}
-	}
-	cbFailures := uint32(5)
-	if v := os.Getenv("PLAYBACK_CB_CONSECUTIVE_FAILS"); v != "" {
-		// ignore invalid values to keep simple
-		if n, err := strconv.Atoi(v); err == nil && n > 0 {
-			cbFailures = uint32(n)
-		}
-	}
+	// Circuit breaker for blob downloads
+	cbFailures := uint32(bc.BreakerFailures)
 	breaker := gobreaker.NewCircuitBreaker(gobreaker.Settings{
 		Name:    "azure-download",
-		Timeout: cbTimeout,
+		Timeout: bc.BreakerReset,
 		ReadyToTrip: func(c gobreaker.Counts) bool {
 			return c.ConsecutiveFailures >= cbFailures
 		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			log.Warnw("circuit breaker state change", "name", name, "from", from.String(), "to", to.String())
+			metrics.BreakerStateChange(name, from, to)
+		},
+		// A missing blob or an out-of-range read is a client problem, not a
+		// storage outage.
+		IsSuccessful: func(err error) bool {
+			return err == nil || errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidRange)
+		},
 	})
-	return &Handler{
-		db:              db,
-		log:             log,
-	client:          httpClient,
-		containerClient: cc,
-		account:         account,
-		containerName:   containerName,
-		cache:           cacheService,
-	breaker:         breaker,
+	// Cache header policy (YAML, validated at load)
+	policy := cachepolicy.Default()
+	if f := cfg.Playback.CachePolicyFile; f != "" {
+		if p, err := cachepolicy.Load(f); err != nil {
+			log.Errorw("cache policy not loaded; using defaults", "file", f, "err", err)
+		} else {
+			policy = p
+		}
 	}
-}
-
-// GET /playback/videos/:uploadId
-func (h *Handler) GetDescriptor(c *gin.Context) {
-	uploadID := c.Param("uploadId")
-	var v models.Video
-	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
-		return
+	// Bearer token keys (HS256 secret and/or RS256 public key)
+	ac := cfg.Auth
+	verifier, err := auth.NewVerifier(ac.JWTSecret, ac.JWTPublicKey, ac.JWTIssuer, ac.JWTAudience)
+	if err != nil {
+		log.Errorw("jwt verifier", "err", err)
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"uploadId":    v.UploadID,
-		"title":       v.Title,
-		"description": v.Description,
-		"tags":        v.Tags,
-		"category":    v.Category,
-		"duration":    v.Duration,
-		"hls": gin.H{
-			"master": c.FullPath() + "/master.m3u8", // will rewrite below
-		},
-	})
+	if verifier == nil {
+		log.Warn("jwt verification not configured; private videos are not playable")
+	}
+	// Signed playback URLs
+	signer := auth.NewURLSigner(ac.URLSigningKey, ac.URLTokenTTL, ac.URLTokenBindIP)
+	pc := cfg.Playback
+	h := &Handler{
+		db:             db,
+		log:            log,
+		store:          store,
+		containerName:  bc.AzureContainer,
+		cache:          cacheService,
+		breaker:        breaker,
+		maxCacheObject: bc.MaxCacheObject,
+		attemptTimeout: bc.AttemptTimeout,
+		retries:        bc.Retries,
+		renditionCache: newTTLMap[*renditionSet](pc.RenditionTTL),
+		verifier:       verifier,
+		signer:         signer,
+		requireSigned:  ac.RequireSignedURLs,
+		fetchTimeout:   bc.FetchTimeout,
+		prefetch:       newPrefetcher(pc.PrefetchSegments, pc.PrefetchQueue),
+		playlists:      newTTLMap[*hls.MediaPlaylist](pc.RenditionTTL),
+		cachePolicy:    policy,
+		videos:         newVideoCache(pc.VideoTTL, pc.VideoNegativeTTL),
+		readyTimeout:   cfg.Server.ReadyTimeout,
+		blobProbe:      bc.ProbeKey,
+		publicURL:      strings.TrimRight(cfg.Server.PublicURL, "/"),
+		trustedProxies: cfg.Server.Proxies(),
+		adminToken:     cfg.Server.AdminToken,
+	}
+	if pc.PrefetchSegments > 0 {
+		h.startPrefetchers(pc.PrefetchWorkers)
+	}
+	bg, stop := context.WithCancel(context.Background())
+	h.stop = stop
+	go h.listenPurges(bg)
+	return h, nil
 }
 
 // Proxy master playlist; rewrite variant URIs to proxy endpoints.
 func (h *Handler) GetMaster(c *gin.Context) {
 	uploadID := c.Param("uploadId")
 	var v models.Video
-	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
 		c.String(http.StatusNotFound, "not found")
 		return
 	}
+	grant, ok := h.authorizeMedia(c, &v, "")
+	if !ok {
+		return
+	}
 	if v.HLSMasterURL == "" {
 		c.String(http.StatusBadRequest, "master not ready")
 		return
 	}
-	if h.containerClient == nil {
-		// fallback to original HTTP (likely public) path
-		resp, err := h.client.Get(v.HLSMasterURL)
-		if err != nil {
-			h.log.Errorw("fetch master", "err", err)
-			c.String(http.StatusBadGateway, "upstream error")
-			return
-		}
-		defer resp.Body.Close()
-		b, _ := io.ReadAll(resp.Body)
-		// Naive rewrite of rendition lines (<res>/index.m3u8)
-		re := regexp.MustCompile(`(?m)^(1080p|720p|480p|360p)/index.m3u8$`)
-		rewritten := re.ReplaceAllStringFunc(string(b), func(s string) string {
-			parts := strings.Split(s, "/")
-			return path.Join(parts[0], "index.m3u8")
-		})
-		c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		c.String(http.StatusOK, rewritten)
+	set, err := h.renditions(c.Request.Context(), &v)
+	if err != nil {
+		h.log.Errorw("master load", "err", err)
+		playlistError(c, err)
 		return
 	}
-	// Private: fetch blob path derived from stored URL
-	blobPath := h.extractBlobPath(v.HLSMasterURL)
-	data, err := h.downloadBlob(c, blobPath)
+	master, err := hls.DecodeMaster(set.MasterData)
 	if err != nil {
-		h.log.Errorw("master download", "err", err)
-		c.String(http.StatusBadGateway, "blob error")
+		c.String(http.StatusBadGateway, "invalid master playlist")
 		return
 	}
-	// Naive rewrite of rendition lines (<res>/index.m3u8)
-	re := regexp.MustCompile(`(?m)^(1080p|720p|480p|360p)/index.m3u8$`)
-	rewritten := re.ReplaceAllStringFunc(string(data), func(s string) string {
-		parts := strings.Split(s, "/")
-		return path.Join(parts[0], "index.m3u8")
-	})
-	c.Header("Content-Type", "application/vnd.apple.mpegurl")
-	c.String(http.StatusOK, rewritten)
+	rewriteMaster(master, set, grant)
+	h.cacheHeaders(c, cachepolicy.Master, &v, grant.query() != "", h.live(c.Request.Context(), set))
+	body := master.Encode()
+	if notModified(c, contentETag(body), time.Time{}) {
+		return
+	}
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", body)
+}
+
+// GET /playback/videos/:uploadId/:rendition/*file
+//
+// Serves the files of one rendition: its media playlist (index.m3u8), its
+// I-frame playlist (iframes.m3u8) and its segments, which may sit in
+// subdirectories of the rendition.
+func (h *Handler) GetRenditionFile(c *gin.Context) {
+	switch strings.TrimPrefix(c.Param("file"), "/") {
+	case "index.m3u8":
+		h.GetVariant(c)
+	case "iframes.m3u8":
+		h.GetIFrames(c)
+	default:
+		h.GetSegment(c)
+	}
 }
 
 // Variant playlist
 func (h *Handler) GetVariant(c *gin.Context) {
+	h.serveMediaPlaylist(c, false)
+}
+
+// GetIFrames serves a rendition's I-frame playlist, listed in the master
+// playlist by #EXT-X-I-FRAME-STREAM-INF.
+func (h *Handler) GetIFrames(c *gin.Context) {
+	h.serveMediaPlaylist(c, true)
+}
+
+func (h *Handler) serveMediaPlaylist(c *gin.Context, iframes bool) {
 	uploadID := c.Param("uploadId")
 	rendition := c.Param("rendition")
-	if !allowedRendition(rendition) {
+	if !renditionName.MatchString(rendition) {
 		c.String(http.StatusBadRequest, "invalid rendition")
 		return
 	}
 	var v models.Video
-	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
 		c.String(http.StatusNotFound, "not found")
 		return
 	}
-	if h.containerClient != nil { // private blob path
-		base := h.blobBase(v.HLSMasterURL)
-		blobPath := base + "/" + rendition + "/index.m3u8"
-		data, err := h.downloadBlob(c, blobPath)
-		if err != nil {
-			h.log.Errorw("variant download", "err", err)
-			c.String(http.StatusBadGateway, "blob error")
+	grant, ok := h.authorizeMedia(c, &v, rendition)
+	if !ok {
+		return
+	}
+	var blobPath string
+	if iframes {
+		if blobPath, ok = h.lookupIFrames(c, &v, rendition); !ok {
 			return
 		}
-		c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		c.String(http.StatusOK, string(data))
+	} else {
+		r, ok := h.lookupRendition(c, &v, rendition)
+		if !ok {
+			return
+		}
+		blobPath = r.PlaylistPath
+	}
+	data, err := h.downloadBlob(c.Request.Context(), blobPath)
+	if err != nil {
+		h.log.Errorw("variant download", "err", err)
+		blobError(c, err)
+		return
+	}
+	media, err := hls.DecodeMedia(data)
+	if err != nil {
+		h.log.Errorw("variant parse", "path", blobPath, "err", err)
+		c.String(http.StatusBadGateway, "invalid variant playlist")
 		return
 	}
-	base := baseHLSPath(v.HLSMasterURL)
-	url := base + "/" + rendition + "/index.m3u8"
-	proxyM3U8(c, h.client, url)
+	live := !media.IsVOD()
+	rewriteMedia(media, grant)
+	h.cacheHeaders(c, cachepolicy.Variant, &v, grant.query() != "", live)
+	body := media.Encode()
+	if notModified(c, contentETag(body), time.Time{}) {
+		return
+	}
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", body)
 }
 
 // Segment
 func (h *Handler) GetSegment(c *gin.Context) {
 	uploadID := c.Param("uploadId")
 	rendition := c.Param("rendition")
-	segment := c.Param("segment")
-	if !allowedRendition(rendition) || !strings.HasSuffix(segment, ".ts") && !strings.HasSuffix(segment, ".m4s") {
+	segment := strings.TrimPrefix(c.Param("file"), "/")
+	if !renditionName.MatchString(rendition) || !allowedSegment(segment) {
 		c.String(http.StatusBadRequest, "invalid segment")
 		return
 	}
 	var v models.Video
-	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
 		c.String(http.StatusNotFound, "not found")
 		return
 	}
-	if h.containerClient != nil { // private
-		base := h.blobBase(v.HLSMasterURL)
-		blobPath := base + "/" + rendition + "/" + segment
-
-		// Try cache first
-		var data []byte
-		var err error
-
-		if h.cache != nil {
-			cacheKey := h.cache.GenerateKey("segment", uploadID, blobPath)
-			data, err = h.cache.Get(c.Request.Context(), cacheKey)
-			if err != nil {
-				h.log.Warnw("cache get error", "err", err)
-			}
-		}
-
-		// Cache miss or no cache - fetch from Azure
-		if data == nil {
-			data, err = h.downloadBlob(c, blobPath)
-			if err != nil {
-				h.log.Errorw("segment download", "err", err)
-				c.String(http.StatusBadGateway, "blob error")
-				return
-			}
-
-			// Store in cache if available
-			if h.cache != nil && data != nil {
-				cacheKey := h.cache.GenerateKey("segment", uploadID, blobPath)
-				if err := h.cache.Set(c.Request.Context(), cacheKey, data); err != nil {
-					h.log.Warnw("cache set error", "err", err)
-				}
-			}
-		}
-
-		// Basic content-type guess
-		switch {
-		case strings.HasSuffix(segment, ".m3u8"):
-			c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		case strings.HasSuffix(segment, ".m4s"):
-			// CMAF/fMP4 segments
-			c.Header("Content-Type", "video/iso.segment")
-		case strings.HasSuffix(segment, ".ts"):
-			c.Header("Content-Type", "video/mp2t")
-		default:
-			c.Header("Content-Type", "application/octet-stream")
-		}
-		c.Header("Cache-Control", "public, max-age=60")
-		c.Data(http.StatusOK, c.Writer.Header().Get("Content-Type"), data)
+	if _, ok := h.authorizeMedia(c, &v, rendition); !ok {
 		return
 	}
-	base := baseHLSPath(v.HLSMasterURL)
-	url := base + "/" + rendition + "/" + segment
-	proxyBinary(c, h.client, url)
+	r, ok := h.lookupRendition(c, &v, rendition)
+	if !ok {
+		return
+	}
+	if c.Request.Method == http.MethodGet {
+		h.prefetchAfter(uploadID, r, segment)
+	}
+	// Segments of a finished (VOD) playlist never change.
+	p, err := h.mediaPlaylist(c.Request.Context(), r.PlaylistPath)
+	h.cacheHeaders(c, cachepolicy.Segment, &v, false, err != nil || !p.IsVOD())
+	blobPath := path.Join(path.Dir(r.PlaylistPath), segment)
+	h.streamBlob(c, "segment", uploadID, blobPath, contentTypeFor(segment))
 }
 
 // GetThumbnail serves video thumbnails
 func (h *Handler) GetThumbnail(c *gin.Context) {
 	uploadID := c.Param("uploadId")
 	var v models.Video
-	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
 		c.String(http.StatusNotFound, "Video not found")
 		return
 	}
+	if _, ok := h.authorizeMedia(c, &v, ""); !ok {
+		return
+	}
 
 	if v.ThumbnailURL == "" {
 		c.String(http.StatusNotFound, "Thumbnail not available")
 		return
 	}
 
-	if h.containerClient != nil {
-		// Private blob: serve from Azure storage with caching
-		thumbnailPath := fmt.Sprintf("thumbnails/%s/%s.jpg", v.UserID, v.UploadID)
-
-		// Try cache first
-		var data []byte
-		var err error
-
-		if h.cache != nil {
-			cacheKey := h.cache.GenerateKey("thumbnail", uploadID, thumbnailPath)
-			data, err = h.cache.Get(c.Request.Context(), cacheKey)
-			if err != nil {
-				h.log.Warnw("cache get error", "err", err)
-			}
-		}
-
-		// Cache miss or no cache - fetch from Azure
-		if data == nil {
-			data, err = h.downloadBlob(c, thumbnailPath)
-			if err != nil {
-				h.log.Errorw("thumbnail download", "err", err)
-				c.String(http.StatusNotFound, "Thumbnail not found")
-				return
-			}
+	thumbnailPath := fmt.Sprintf("thumbnails/%s/%s.jpg", v.UserID, v.UploadID)
+	h.cacheHeaders(c, cachepolicy.Thumbnail, &v, false, false)
+	h.streamBlob(c, "thumbnail", uploadID, thumbnailPath, "image/jpeg")
+}
 
-			// Store in cache if available
-			if h.cache != nil && data != nil {
-				cacheKey := h.cache.GenerateKey("thumbnail", uploadID, thumbnailPath)
-				if err := h.cache.Set(c.Request.Context(), cacheKey, data); err != nil {
-					h.log.Warnw("cache set error", "err", err)
-				}
-			}
-		}
+// lookupRendition resolves a rendition against the video's master playlist,
+// writing the error response itself when it is unknown or cannot be loaded.
+func (h *Handler) lookupRendition(c *gin.Context, v *models.Video, name string) (*rendition, bool) {
+	set, ok := h.renditionsFor(c, v)
+	if !ok {
+		return nil, false
+	}
+	r, ok := set.lookup(name)
+	if !ok {
+		c.String(http.StatusNotFound, "unknown rendition")
+		return nil, false
+	}
+	return r, true
+}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Header("Cache-Control", "public, max-age=3600")
-		c.Data(http.StatusOK, "image/jpeg", data)
-		return
+// lookupIFrames is lookupRendition for I-frame playlists, returning the
+// playlist's blob path.
+func (h *Handler) lookupIFrames(c *gin.Context, v *models.Video, name string) (string, bool) {
+	set, ok := h.renditionsFor(c, v)
+	if !ok {
+		return "", false
 	}
+	blobPath, ok := set.IFrames[name]
+	if !ok {
+		c.String(http.StatusNotFound, "unknown rendition")
+		return "", false
+	}
+	return blobPath, true
+}
 
-	// Public blob: redirect to direct URL
-	c.Redirect(http.StatusFound, v.ThumbnailURL)
+func (h *Handler) renditionsFor(c *gin.Context, v *models.Video) (*renditionSet, bool) {
+	if v.HLSMasterURL == "" {
+		c.String(http.StatusNotFound, "master not ready")
+		return nil, false
+	}
+	set, err := h.renditions(c.Request.Context(), v)
+	if err != nil {
+		h.log.Errorw("rendition discovery", "uploadId", v.UploadID, "err", err)
+		playlistError(c, err)
+		return nil, false
+	}
+	return set, true
 }
 
-func allowedRendition(r string) bool {
-	switch r {
-	case "1080p", "720p", "480p", "360p":
+// allowedSegment accepts TS and CMAF media segments plus fMP4 init and
+// single-file renditions addressed with #EXT-X-BYTERANGE. Segments may sit
+// in subdirectories of the rendition but every path element must be a
+// plain name.
+func allowedSegment(s string) bool {
+	for _, elem := range strings.Split(s, "/") {
+		if !renditionName.MatchString(elem) {
+			return false
+		}
+	}
+	switch path.Ext(s) {
+	case ".ts", ".m4s", ".mp4":
 		return true
 	}
 	return false
 }
 
-func baseHLSPath(master string) string {
-	// master URL ends with master.m3u8; strip
-	return strings.TrimSuffix(master, "/master.m3u8")
+// contentTypeFor guesses the media type from the object name.
+func contentTypeFor(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".m3u8"):
+		return "application/vnd.apple.mpegurl"
+	case strings.HasSuffix(name, ".m4s"):
+		// CMAF/fMP4 segments
+		return "video/iso.segment"
+	case strings.HasSuffix(name, ".ts"):
+		return "video/mp2t"
+	case strings.HasSuffix(name, ".mp4"):
+		return "video/mp4"
+	case strings.HasSuffix(name, ".jpg"):
+		return "image/jpeg"
+	}
+	return "application/octet-stream"
 }
 
-func proxyM3U8(c *gin.Context, cl *http.Client, url string) {
-	resp, err := cl.Get(url)
-	if err != nil {
-		c.String(http.StatusBadGateway, "upstream error")
+// blobError maps a blob store failure onto a response.
+func blobError(c *gin.Context, err error) {
+	if errors.Is(err, storage.ErrNotFound) {
+		c.String(http.StatusNotFound, "not found")
 		return
 	}
-	defer resp.Body.Close()
-	b, _ := io.ReadAll(resp.Body)
-	c.Header("Content-Type", "application/vnd.apple.mpegurl")
-	c.String(resp.StatusCode, string(b))
+	c.String(http.StatusBadGateway, "blob error")
 }
 
-func proxyBinary(c *gin.Context, cl *http.Client, url string) {
-	resp, err := cl.Get(url)
-	if err != nil {
-		c.String(http.StatusBadGateway, "upstream error")
+// playlistError maps a master playlist load failure onto a response.
+func playlistError(c *gin.Context, err error) {
+	var perr *hls.ParseError
+	if errors.As(err, &perr) {
+		c.String(http.StatusBadGateway, "invalid master playlist")
 		return
 	}
-	defer resp.Body.Close()
-	// Forward known headers if present, otherwise derive based on URL
-	ct := resp.Header.Get("Content-Type")
-	if ct == "" {
-		switch {
-		case strings.HasSuffix(url, ".m3u8"):
-			ct = "application/vnd.apple.mpegurl"
-		case strings.HasSuffix(url, ".m4s"):
-			ct = "video/iso.segment"
-		case strings.HasSuffix(url, ".ts"):
-			ct = "video/mp2t"
-		default:
-			ct = "application/octet-stream"
-		}
-	}
-	c.Header("Content-Type", ct)
-	c.Header("Cache-Control", "public, max-age=60")
-	c.Status(resp.StatusCode)
-	io.Copy(c.Writer, resp.Body)
+	blobError(c, err)
 }
 
-// Optional simple readiness endpoint
-func (h *Handler) Ready(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status": "ok"}) }
-
 // Debug config
 func (h *Handler) Config(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"env": os.Environ()}) }
 
-func (h *Handler) downloadBlob(c *gin.Context, path string) ([]byte, error) {
-	// Retry with backoff and breaker; per-attempt timeout (env: PLAYBACK_AZURE_TIMEOUT_MS)
-	attemptTimeout := 3 * time.Second
-	if v := os.Getenv("PLAYBACK_AZURE_TIMEOUT_MS"); v != "" {
-		if d, err := time.ParseDuration(v + "ms"); err == nil {
-			attemptTimeout = d
-		}
-	}
-	retries := 2
-	if v := os.Getenv("PLAYBACK_AZURE_RETRIES"); v != "" {
-		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
-			retries = n
+// downloadBlob reads a whole (small) blob such as a playlist into memory,
+// sharing the download with concurrent callers.
+func (h *Handler) downloadBlob(ctx context.Context, path string) ([]byte, error) {
+	res, err := h.shared(ctx, "playlist:"+path, func(ctx context.Context) (interface{}, error) {
+		body, _, err := h.openBlob(ctx, path, 0, 0)
+		if err != nil {
+			return nil, err
 		}
+		defer body.Close()
+		return io.ReadAll(body)
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	var lastErr error
-	backoff := 200 * time.Millisecond
-	for i := 0; i <= retries; i++ {
-		ctx, cancel := context.WithTimeout(c.Request.Context(), attemptTimeout)
-		bc := h.containerClient.NewBlobClient(path)
-		res, err := h.breaker.Execute(func() (interface{}, error) {
-			resp, err := bc.DownloadStream(ctx, nil)
-			if err != nil {
-				return nil, err
-			}
-			b, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return nil, err
-			}
-			return b, nil
-		})
-		cancel()
-		if err == nil {
-			if data, ok := res.([]byte); ok {
-				return data, nil
-			}
-			return nil, fmt.Errorf("unexpected breaker result")
-		}
-		lastErr = err
-		if i < retries {
-			time.Sleep(backoff)
-			if backoff < 1500*time.Millisecond {
-				backoff *= 2
-			}
-		}
+	data, ok := res.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected fetch result")
 	}
-	return nil, lastErr
-}
-
-// Helper to compute base path inside container (without container prefix and without master.m3u8)
-func (h *Handler) blobBase(masterURL string) string {
-	blobPath := h.extractBlobPath(masterURL)
-	return strings.TrimSuffix(blobPath, "/master.m3u8")
+	return data, nil
 }
 
 // extractBlobPath extracts the blob path from either a full Azure URL or relative path
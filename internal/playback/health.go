@@ -0,0 +1,152 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sony/gobreaker"
+
+	"github.com/streamhive/playback-service/internal/storage"
+)
+
+// Dependency and overall readiness states.
+const (
+	statusOK       = "ok"
+	statusDegraded = "degraded"
+	statusFailing  = "failing"
+	statusDraining = "draining"
+)
+
+var errBreakerOpen = errors.New("blob circuit breaker is open")
+
+type checkResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// GET /livez
+//
+// The process is up and serving HTTP; dependencies are not consulted so a
+// database outage does not get pods restarted.
+func (h *Handler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": statusOK})
+}
+
+// GET /readyz
+//
+// Checks the database, Redis, the blob store and the blob circuit breaker.
+// Redis problems only degrade readiness since playback works without the
+// cache; an open breaker does too, since it closes again on its own and
+// pulling every pod at once would only turn a blob outage into a full one.
+// Fails while the pod is draining.
+func (h *Handler) Readyz(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": statusDraining})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), h.readyTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) (string, error){
+		"database": h.checkDB,
+		"cache":    h.checkCache,
+		"blob":     h.checkBlob,
+		"breaker":  h.checkBreaker,
+	}
+	results := make(map[string]checkResult, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) (string, error)) {
+			defer wg.Done()
+			start := time.Now()
+			status, err := check(ctx)
+			r := checkResult{Status: status, LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				r.Error = err.Error()
+			}
+			mu.Lock()
+			results[name] = r
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	overall, code := statusOK, http.StatusOK
+	for _, r := range results {
+		switch r.Status {
+		case statusFailing:
+			overall, code = statusFailing, http.StatusServiceUnavailable
+		case statusDegraded:
+			if overall == statusOK {
+				overall = statusDegraded
+			}
+		}
+	}
+	c.JSON(code, gin.H{"status": overall, "checks": results})
+}
+
+// StartDrain makes readiness fail so the pod is taken out of rotation
+// before it shuts down.
+func (h *Handler) StartDrain() {
+	h.draining.Store(true)
+}
+
+func (h *Handler) checkDB(ctx context.Context) (string, error) {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return statusFailing, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return statusFailing, err
+	}
+	return statusOK, nil
+}
+
+func (h *Handler) checkCache(ctx context.Context) (string, error) {
+	p, ok := h.cache.(interface{ Ping(context.Context) error })
+	if !ok {
+		return statusOK, nil // in-memory or disabled
+	}
+	if err := p.Ping(ctx); err != nil {
+		return statusDegraded, err
+	}
+	return statusOK, nil
+}
+
+// checkBlob reads the properties of the configured probe blob, or of the
+// container when there is none. A missing probe blob still shows the store
+// is reachable, so it only degrades readiness.
+func (h *Handler) checkBlob(ctx context.Context) (string, error) {
+	if h.blobProbe == "" {
+		if err := h.store.Ping(ctx); err != nil {
+			return statusFailing, err
+		}
+		return statusOK, nil
+	}
+	_, err := h.store.Stat(ctx, h.blobProbe)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return statusDegraded, err
+	case err != nil:
+		return statusFailing, err
+	}
+	return statusOK, nil
+}
+
+func (h *Handler) checkBreaker(context.Context) (string, error) {
+	switch state := h.breaker.State(); state {
+	case gobreaker.StateOpen:
+		return statusDegraded, errBreakerOpen
+	case gobreaker.StateHalfOpen:
+		return statusDegraded, nil
+	default:
+		return statusOK, nil
+	}
+}
@@ -0,0 +1,52 @@
+package playback
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/streamhive/playback-service/internal/cachepolicy"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// GET /playback/videos/:uploadId/keys/:keyId
+//
+// Serves the raw 16-byte AES-128 content key referenced by #EXT-X-KEY in the
+// rewritten variant playlists. Access follows the segment routes.
+func (h *Handler) GetKey(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	keyID := c.Param("keyId")
+	if !renditionName.MatchString(keyID) {
+		c.String(http.StatusBadRequest, "invalid key")
+		return
+	}
+	var v models.Video
+	if err := h.findVideo(c.Request.Context(), uploadID, &v); err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	if _, ok := h.authorizeMedia(c, &v, ""); !ok {
+		return
+	}
+	var k models.VideoKey
+	err := h.db.WithContext(c.Request.Context()).Where("upload_id = ? AND key_id = ?", uploadID, keyID).First(&k).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusNotFound, "key not found")
+		return
+	}
+	if err != nil {
+		h.log.Errorw("key lookup", "uploadId", uploadID, "keyId", keyID, "err", err)
+		c.String(http.StatusInternalServerError, "key lookup failed")
+		return
+	}
+	if len(k.Key) != 16 {
+		h.log.Errorw("stored key has wrong length", "uploadId", uploadID, "keyId", keyID, "len", len(k.Key))
+		c.String(http.StatusInternalServerError, "invalid key")
+		return
+	}
+	// Keys must never be stored by shared caches.
+	h.cacheHeaders(c, cachepolicy.Key, &v, true, false)
+	c.Data(http.StatusOK, "application/octet-stream", k.Key)
+}
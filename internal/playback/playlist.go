@@ -0,0 +1,121 @@
+package playback
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/streamhive/playback-service/internal/hls"
+)
+
+// rewriteMaster points every variant and alternate rendition at the proxy
+// route <rendition>/index.m3u8 and I-frame playlists at
+// <rendition>/iframes.m3u8, relative to the master playlist, drops the
+// renditions the grant does not cover and appends the grant's token. m is a
+// copy of set's master playlist, which names the renditions.
+func rewriteMaster(m *hls.MasterPlaylist, set *renditionSet, g *mediaGrant) {
+	q := g.query()
+	variants := m.Variants[:0]
+	for _, v := range m.Variants {
+		name := set.nameOf(v.URI)
+		if !g.allowsRendition(name) {
+			continue
+		}
+		v.URI = withQuery(name+"/index.m3u8", q)
+		variants = append(variants, v)
+	}
+	m.Variants = variants
+	iframes := m.IFrameVariants[:0]
+	for _, v := range m.IFrameVariants {
+		name := set.iframeNameOf(v.URI)
+		if !g.allowsRendition(name) {
+			continue
+		}
+		v.URI = withQuery(name+"/iframes.m3u8", q)
+		iframes = append(iframes, v)
+	}
+	m.IFrameVariants = iframes
+	for _, media := range m.Media {
+		if media.URI != "" {
+			media.URI = withQuery(set.nameOf(media.URI)+"/index.m3u8", q)
+		}
+	}
+	rewriteKeys(m.SessionKeys, "keys/", q)
+}
+
+// rewriteMedia makes segment and init section URIs relative to the variant
+// playlist so they resolve to the segment route, carrying the grant's token.
+func rewriteMedia(p *hls.MediaPlaylist, g *mediaGrant) {
+	q := g.query()
+	for _, s := range p.Segments {
+		s.URI = withQuery(hls.SegmentPath(s.URI), q)
+		if s.Map != nil {
+			s.Map.URI = withQuery(hls.SegmentPath(s.Map.URI), q)
+		}
+		rewriteKeys(s.Keys, "../keys/", q)
+	}
+}
+
+// rewriteKeys points identity-format AES keys at the key route. prefix is
+// the route relative to the playlist being rewritten. Keys for other key
+// systems (FairPlay, Widevine, ...) are left untouched.
+func rewriteKeys(keys []*hls.Key, prefix, query string) {
+	for _, k := range keys {
+		if k.Method == "NONE" || k.URI == "" || (k.KeyFormat != "" && k.KeyFormat != "identity") {
+			continue
+		}
+		if id := keyIDOf(k.URI); id != "" {
+			k.URI = withQuery(prefix+id, query)
+		}
+	}
+}
+
+// keyIDOf derives the key ID from a key URI: its file name without extension,
+// e.g. "k1" for "keys/k1.key" or "https://kms.example/k1".
+func keyIDOf(uri string) string {
+	if strings.HasPrefix(uri, "data:") || strings.HasPrefix(uri, "skd:") {
+		return ""
+	}
+	name := baseName(uri)
+	id := strings.TrimSuffix(name, path.Ext(name))
+	if !renditionName.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+func withQuery(uri, query string) string {
+	if query == "" {
+		return uri
+	}
+	return uri + "?" + query
+}
+
+// renditionOf returns the directory a playlist URI lives in, which is the
+// rendition name, e.g. "720p" for "720p/index.m3u8" or an absolute blob URL.
+// Playlists stored next to the master are named after their file instead.
+// Names of the playlists of one master come from renditionSet.nameOf, which
+// also tells apart playlists sharing a directory.
+func renditionOf(uri string) string {
+	p := uriPath(uri)
+	if dir := path.Base(path.Dir(p)); dir != "." && dir != "/" {
+		return dir
+	}
+	name := path.Base(p)
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+func baseName(uri string) string {
+	return path.Base(uriPath(uri))
+}
+
+// uriPath strips scheme, host and query from a playlist URI.
+func uriPath(uri string) string {
+	if u, err := url.Parse(uri); err == nil {
+		return u.Path
+	}
+	if i := strings.IndexAny(uri, "?#"); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
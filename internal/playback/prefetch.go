@@ -0,0 +1,171 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/hls"
+)
+
+// prefetchJob asks the pool to warm the segments following one a viewer
+// just requested.
+type prefetchJob struct {
+	key      string
+	uploadID string
+	r        *rendition
+	segment  string
+}
+
+// prefetcher warms the cache for upcoming segments on a bounded worker pool.
+// Jobs for the same segment are deduplicated while queued or running, and
+// the fetches themselves share in-flight downloads with viewer requests.
+type prefetcher struct {
+	depth   int
+	queue   chan prefetchJob
+	mu      sync.Mutex
+	pending map[string]struct{}
+	closed  bool
+	// ctx is cancelled on shutdown to abandon queued and running jobs.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newPrefetcher(depth, queue int) *prefetcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &prefetcher{
+		depth:   depth,
+		queue:   make(chan prefetchJob, queue),
+		pending: make(map[string]struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (h *Handler) startPrefetchers(workers int) {
+	p := h.prefetch
+	for i := 0; i < workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for job := range p.queue {
+				if p.ctx.Err() == nil {
+					h.runPrefetch(p.ctx, job)
+				}
+				p.done(job.key)
+			}
+		}()
+	}
+}
+
+// stop rejects new jobs, abandons queued ones and waits for the workers.
+func (p *prefetcher) stop() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+	p.cancel()
+	p.wg.Wait()
+}
+
+// prefetchAfter queues warming of the segments after segment. It never
+// blocks: when the queue is full the job is dropped.
+func (h *Handler) prefetchAfter(uploadID string, r *rendition, segment string) {
+	p := h.prefetch
+	if p == nil || p.depth <= 0 {
+		return
+	}
+	key := r.PlaylistPath + "|" + segment
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.pending[key]; ok || p.closed {
+		return
+	}
+	select {
+	case p.queue <- prefetchJob{key: key, uploadID: uploadID, r: r, segment: segment}:
+		p.pending[key] = struct{}{}
+	default:
+	}
+}
+
+func (p *prefetcher) done(key string) {
+	p.mu.Lock()
+	delete(p.pending, key)
+	p.mu.Unlock()
+}
+
+func (h *Handler) runPrefetch(ctx context.Context, job prefetchJob) {
+	ctx, cancel := context.WithTimeout(ctx, h.fetchTimeout)
+	defer cancel()
+
+	media, err := h.mediaPlaylist(ctx, job.r.PlaylistPath)
+	if err != nil {
+		h.log.Debugw("prefetch playlist", "path", job.r.PlaylistPath, "err", err)
+		return
+	}
+	dir := path.Dir(job.r.PlaylistPath)
+	for _, blobPath := range nextSegments(media, job.segment, h.prefetch.depth) {
+		blobPath = path.Join(dir, blobPath)
+		cacheKey := cache.GenerateKey("segment", job.uploadID, blobPath)
+		if n, _, err := h.cache.Stat(ctx, cacheKey); err == nil && n > 0 {
+			continue
+		}
+		if err := h.warmObject(ctx, job.uploadID, blobPath, cacheKey); err != nil && !errors.Is(err, errTooLarge) {
+			h.log.Debugw("prefetch segment", "path", blobPath, "err", err)
+			return
+		}
+	}
+}
+
+// warmObject reads a blob into the cache through the shared download path.
+// Objects too large to cache are closed as soon as their size is known.
+func (h *Handler) warmObject(ctx context.Context, uploadID, blobPath, cacheKey string) error {
+	body, info, err := h.openShared(ctx, uploadID, blobPath, cacheKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if info.Size > h.maxCacheObject {
+		return errTooLarge
+	}
+	_, err = io.Copy(io.Discard, body)
+	return err
+}
+
+// nextSegments returns the paths, relative to the playlist, of up to n
+// distinct segments following segment in the playlist. Byte-range segments
+// of the same file collapse into one entry.
+func nextSegments(p *hls.MediaPlaylist, segment string, n int) []string {
+	idx := -1
+	for i, s := range p.Segments {
+		if hls.SegmentPath(s.URI) == segment {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil
+	}
+	var out []string
+	last := segment
+	for _, s := range p.Segments[idx+1:] {
+		if len(out) == n {
+			break
+		}
+		name := hls.SegmentPath(s.URI)
+		if name == last || !allowedSegment(name) {
+			continue
+		}
+		out = append(out, name)
+		last = name
+	}
+	return out
+}
@@ -0,0 +1,88 @@
+package playback
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	errMultiRange     = errors.New("multiple ranges are not supported")
+	errUnsatisfiable  = errors.New("range not satisfiable")
+	errMalformedRange = errors.New("malformed range")
+)
+
+// byteRange is a single parsed "bytes=" range. Either start is set
+// (end == -1 meaning "to the end"), or suffix holds the length of a
+// "bytes=-N" range.
+type byteRange struct {
+	start  int64
+	end    int64
+	suffix int64
+}
+
+// parseRange parses a Range header. Only a single byte range is accepted;
+// malformed headers are reported so the caller can ignore them per RFC 9110.
+func parseRange(header string) (byteRange, error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return byteRange{}, errMalformedRange
+	}
+	if strings.Contains(spec, ",") {
+		return byteRange{}, errMultiRange
+	}
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return byteRange{}, errMalformedRange
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n < 0 {
+			return byteRange{}, errMalformedRange
+		}
+		if n == 0 {
+			return byteRange{}, errUnsatisfiable
+		}
+		return byteRange{suffix: n, end: -1}, nil
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, errMalformedRange
+	}
+	r := byteRange{start: start, end: -1}
+	if last != "" {
+		end, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, errMalformedRange
+		}
+		r.end = end
+	}
+	return r, nil
+}
+
+// resolve turns the range into absolute inclusive offsets for an object of
+// the given size.
+func (r byteRange) resolve(size int64) (start, end int64, err error) {
+	if r.suffix > 0 {
+		if size == 0 {
+			return 0, 0, errUnsatisfiable
+		}
+		start = size - r.suffix
+		if start < 0 {
+			start = 0
+		}
+		return start, size - 1, nil
+	}
+	if r.start >= size {
+		return 0, 0, errUnsatisfiable
+	}
+	end = r.end
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	return r.start, end, nil
+}
+
+func contentRange(start, end, size int64) string {
+	return "bytes " + strconv.FormatInt(start, 10) + "-" + strconv.FormatInt(end, 10) + "/" + strconv.FormatInt(size, 10)
+}
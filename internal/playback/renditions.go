@@ -0,0 +1,243 @@
+package playback
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/streamhive/playback-service/internal/hls"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// Rendition names come from transcoder output directories; anything else is
+// rejected before it reaches the blob store.
+var renditionName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// rendition is one playable stream of a video as listed by its master playlist.
+type rendition struct {
+	Name string
+	// PlaylistPath is the blob path of the rendition's media playlist.
+	PlaylistPath string
+	// Exactly one of Variant and Media is set.
+	Variant *hls.Variant
+	Media   *hls.Media
+}
+
+// renditionSet is the parsed master playlist of one upload together with the
+// renditions it references, keyed by name.
+type renditionSet struct {
+	MasterPath string
+	// MasterData is the original playlist; handlers that rewrite it decode
+	// their own copy since Master is shared.
+	MasterData []byte
+	Master     *hls.MasterPlaylist
+	Renditions map[string]*rendition
+	// IFrames maps rendition names to the blob paths of their I-frame
+	// playlists.
+	IFrames map[string]string
+	// names and iframeNames map playlist URIs, as written in the master, to
+	// rendition names.
+	names, iframeNames map[string]string
+}
+
+// renditions returns the rendition set for a video, parsing its master
+// playlist on first use and caching the result per upload.
+func (h *Handler) renditions(ctx context.Context, v *models.Video) (*renditionSet, error) {
+	if set, ok := h.renditionCache.Get(v.UploadID); ok {
+		return set, nil
+	}
+	res, err := h.shared(ctx, "renditions:"+v.UploadID, func(ctx context.Context) (interface{}, error) {
+		return h.loadRenditions(ctx, v)
+	})
+	if err != nil {
+		return nil, err
+	}
+	set, ok := res.(*renditionSet)
+	if !ok {
+		return nil, fmt.Errorf("unexpected fetch result")
+	}
+	return set, nil
+}
+
+func (h *Handler) loadRenditions(ctx context.Context, v *models.Video) (*renditionSet, error) {
+	masterPath := h.extractBlobPath(v.HLSMasterURL)
+	data, err := h.downloadBlob(ctx, masterPath)
+	if err != nil {
+		return nil, err
+	}
+	master, err := hls.DecodeMaster(data)
+	if err != nil {
+		return nil, err
+	}
+	set := &renditionSet{
+		MasterPath: masterPath,
+		MasterData: data,
+		Master:     master,
+		Renditions: make(map[string]*rendition),
+		IFrames:    make(map[string]string),
+	}
+	var uris, iframeURIs []string
+	for _, variant := range master.Variants {
+		uris = append(uris, variant.URI)
+	}
+	for _, media := range master.Media {
+		if media.URI != "" {
+			uris = append(uris, media.URI)
+		}
+	}
+	for _, variant := range master.IFrameVariants {
+		iframeURIs = append(iframeURIs, variant.URI)
+	}
+	set.names = h.renditionNames(masterPath, uris)
+	set.iframeNames = h.renditionNames(masterPath, iframeURIs)
+
+	for _, variant := range master.Variants {
+		set.add(h, &rendition{Name: set.nameOf(variant.URI), PlaylistPath: h.resolveURI(masterPath, variant.URI), Variant: variant})
+	}
+	for _, media := range master.Media {
+		if media.URI != "" {
+			set.add(h, &rendition{Name: set.nameOf(media.URI), PlaylistPath: h.resolveURI(masterPath, media.URI), Media: media})
+		}
+	}
+	for _, variant := range master.IFrameVariants {
+		name := set.iframeNameOf(variant.URI)
+		if _, dup := set.IFrames[name]; renditionName.MatchString(name) && !dup {
+			set.IFrames[name] = h.resolveURI(masterPath, variant.URI)
+		}
+	}
+	h.renditionCache.Set(v.UploadID, set)
+	return set, nil
+}
+
+func (s *renditionSet) add(h *Handler, r *rendition) {
+	if !renditionName.MatchString(r.Name) {
+		h.log.Warnw("skipping rendition with unsupported name", "master", s.MasterPath, "name", r.Name)
+		return
+	}
+	// Names are unique per playlist, so a repeat is the same playlist listed
+	// again (e.g. a variant in several audio groups); the first entry wins.
+	if _, dup := s.Renditions[r.Name]; !dup {
+		s.Renditions[r.Name] = r
+	}
+}
+
+// renditionNames names the playlists listed in a master playlist. A playlist
+// is named after its directory (see renditionOf) unless it shares that
+// directory with another playlist, in which case it is named after its file:
+// "hls/1080p.m3u8" and "hls/360p.m3u8" become "1080p" and "360p". Names that
+// still collide get a numeric suffix.
+func (h *Handler) renditionNames(masterPath string, uris []string) map[string]string {
+	byDir := make(map[string]map[string]bool)
+	for _, uri := range uris {
+		p := path.Clean(uriPath(uri))
+		dir := path.Base(path.Dir(p))
+		if byDir[dir] == nil {
+			byDir[dir] = make(map[string]bool)
+		}
+		byDir[dir][p] = true
+	}
+	names := make(map[string]string, len(uris))
+	byPath := make(map[string]string)
+	used := make(map[string]bool)
+	for _, uri := range uris {
+		p := path.Clean(uriPath(uri))
+		if name, ok := byPath[p]; ok {
+			names[uri] = name
+			continue
+		}
+		name := renditionOf(uri)
+		if len(byDir[path.Base(path.Dir(p))]) > 1 {
+			name = strings.TrimSuffix(path.Base(p), path.Ext(p))
+		}
+		if used[name] {
+			unique := name
+			for i := 2; used[unique]; i++ {
+				unique = fmt.Sprintf("%s-%d", name, i)
+			}
+			h.log.Warnw("rendition name collision; renamed", "master", masterPath, "uri", uri, "name", unique)
+			name = unique
+		}
+		used[name] = true
+		byPath[p] = name
+		names[uri] = name
+	}
+	return names
+}
+
+// nameOf returns the rendition name of a variant or alternate rendition
+// playlist listed in the master.
+func (s *renditionSet) nameOf(uri string) string {
+	if name, ok := s.names[uri]; ok {
+		return name
+	}
+	return renditionOf(uri)
+}
+
+// iframeNameOf is nameOf for I-frame playlists.
+func (s *renditionSet) iframeNameOf(uri string) string {
+	if name, ok := s.iframeNames[uri]; ok {
+		return name
+	}
+	return renditionOf(uri)
+}
+
+// lookup returns the named rendition if the video has it.
+func (s *renditionSet) lookup(name string) (*rendition, bool) {
+	if !renditionName.MatchString(name) {
+		return nil, false
+	}
+	r, ok := s.Renditions[name]
+	return r, ok
+}
+
+// resolveURI turns a URI from a playlist stored at base into a blob path.
+func (h *Handler) resolveURI(base, uri string) string {
+	if strings.Contains(uri, "://") {
+		u, _, _ := strings.Cut(uri, "?")
+		return h.extractBlobPath(u)
+	}
+	p := uriPath(uri)
+	if strings.HasPrefix(p, "/") {
+		return strings.TrimPrefix(p, "/")
+	}
+	return path.Join(path.Dir(base), p)
+}
+
+// mediaPlaylist returns the parsed media playlist at blobPath. VOD playlists
+// are kept for the rendition TTL; live ones only for half a target duration,
+// the usual client reload interval. The result is shared and read-only.
+func (h *Handler) mediaPlaylist(ctx context.Context, blobPath string) (*hls.MediaPlaylist, error) {
+	if p, ok := h.playlists.Get(blobPath); ok {
+		return p, nil
+	}
+	data, err := h.downloadBlob(ctx, blobPath)
+	if err != nil {
+		return nil, err
+	}
+	p, err := hls.DecodeMedia(data)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsVOD() {
+		h.playlists.Set(blobPath, p)
+	} else if ttl := time.Duration(p.TargetDuration) * time.Second / 2; ttl > 0 {
+		h.playlists.SetTTL(blobPath, p, ttl)
+	}
+	return p, nil
+}
+
+// live reports whether the video is still being published, judged by the
+// first variant whose media playlist loads.
+func (h *Handler) live(ctx context.Context, set *renditionSet) bool {
+	for _, variant := range set.Master.Variants {
+		if r, ok := set.lookup(set.nameOf(variant.URI)); ok {
+			if p, err := h.mediaPlaylist(ctx, r.PlaylistPath); err == nil {
+				return !p.IsVOD()
+			}
+		}
+	}
+	return false
+}
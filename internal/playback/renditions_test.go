@@ -0,0 +1,81 @@
+package playback
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/streamhive/playback-service/internal/hls"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+func TestRenditionsSharingADirectory(t *testing.T) {
+	th := newTestHandler(t)
+	th.store.Put("videos/u2/master.m3u8", []byte("#EXTM3U\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=5000000\nhls/1080p.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=800000\nhls/360p.m3u8\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000000\n720p/index.m3u8\n"), "application/vnd.apple.mpegurl")
+	th.store.Put("videos/u2/hls/1080p.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\n1080p_0.ts\n#EXT-X-ENDLIST\n"), "application/vnd.apple.mpegurl")
+	th.store.Put("videos/u2/hls/360p.m3u8", []byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\n360p_0.ts\n#EXT-X-ENDLIST\n"), "application/vnd.apple.mpegurl")
+	v := &models.Video{UploadID: "u2", HLSMasterURL: "videos/u2/master.m3u8"}
+	th.h.videos.entries.Set(v.UploadID, v)
+
+	set, err := th.h.renditions(context.Background(), v)
+	if err != nil {
+		t.Fatalf("renditions: %v", err)
+	}
+	want := map[string]string{
+		"1080p": "videos/u2/hls/1080p.m3u8",
+		"360p":  "videos/u2/hls/360p.m3u8",
+		"720p":  "videos/u2/720p/index.m3u8",
+	}
+	if len(set.Renditions) != len(want) {
+		t.Errorf("got %d renditions, want %d", len(set.Renditions), len(want))
+	}
+	for name, p := range want {
+		if r, ok := set.lookup(name); !ok || r.PlaylistPath != p {
+			t.Errorf("rendition %q = %+v, want playlist %s", name, r, p)
+		}
+	}
+
+	master, err := hls.DecodeMaster(set.MasterData)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	rewriteMaster(master, set, &mediaGrant{})
+	for i, uri := range []string{"1080p/index.m3u8", "360p/index.m3u8", "720p/index.m3u8"} {
+		if got := master.Variants[i].URI; got != uri {
+			t.Errorf("variant %d URI = %q, want %q", i, got, uri)
+		}
+	}
+
+	w := th.do(http.MethodGet, "/playback/videos/u2/360p/index.m3u8", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("variant: %d %s", w.Code, w.Body.String())
+	}
+	p, err := hls.DecodeMedia(w.Body.Bytes())
+	if err != nil || len(p.Segments) != 1 || p.Segments[0].URI != "360p_0.ts" {
+		t.Fatalf("variant playlist %q, %v", w.Body.String(), err)
+	}
+}
+
+func TestRenditionNameCollision(t *testing.T) {
+	th := newTestHandler(t)
+	names := th.h.renditionNames("videos/u3/master.m3u8", []string{
+		"a/x/index.m3u8",
+		"b/x/index.m3u8",
+		"a/x/index.m3u8?v=2",
+		"https://cdn.example/videos/u3/720p/index.m3u8",
+	})
+	want := map[string]string{
+		"a/x/index.m3u8":     "index",
+		"b/x/index.m3u8":     "index-2",
+		"a/x/index.m3u8?v=2": "index",
+		"https://cdn.example/videos/u3/720p/index.m3u8": "720p",
+	}
+	for uri, name := range want {
+		if names[uri] != name {
+			t.Errorf("name of %q = %q, want %q", uri, names[uri], name)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/metrics"
+	"github.com/streamhive/playback-service/internal/storage"
+	"github.com/streamhive/playback-service/internal/tracing"
+)
+
+// withRetry runs op through the circuit breaker with a per-attempt timeout
+// and exponential backoff. Missing blobs and invalid ranges are not retried.
+// When keep is true the attempt context outlives a successful op (it is
+// returned for the caller to cancel) and the timeout only bounds the call.
+func (h *Handler) withRetry(ctx context.Context, name string, keep bool, op func(ctx context.Context) (interface{}, error)) (interface{}, context.CancelFunc, error) {
+	var lastErr error
+	backoff := 200 * time.Millisecond
+	for i := 0; i <= h.retries; i++ {
+		metrics.BlobAttempt(name, i > 0)
+		spanCtx, span := tracing.Tracer().Start(ctx, "blob."+name+".attempt", trace.WithAttributes(
+			attribute.Int("blob.attempt", i),
+			attribute.String("breaker.state", h.breaker.State().String()),
+		))
+		attemptCtx, cancel := context.WithCancel(spanCtx)
+		timer := time.AfterFunc(h.attemptTimeout, cancel)
+		res, err := h.breaker.Execute(func() (interface{}, error) { return op(attemptCtx) })
+		stopped := timer.Stop()
+		if err != nil {
+			span.RecordError(err)
+			if !errors.Is(err, storage.ErrNotFound) && !errors.Is(err, storage.ErrInvalidRange) {
+				span.SetStatus(codes.Error, err.Error())
+			}
+		}
+		span.End()
+		if err == nil && (stopped || !keep) {
+			if keep {
+				return res, cancel, nil
+			}
+			cancel()
+			return res, nil, nil
+		}
+		if err == nil {
+			// The attempt timed out right after op succeeded.
+			if rc, ok := res.(io.Closer); ok {
+				rc.Close()
+			}
+			err = context.DeadlineExceeded
+		}
+		cancel()
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidRange) || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		if i == h.retries {
+			metrics.BlobFailure(name)
+		}
+		lastErr = err
+		if i < h.retries {
+			time.Sleep(backoff)
+			if backoff < 1500*time.Millisecond {
+				backoff *= 2
+			}
+		}
+	}
+	return nil, nil, lastErr
+}
+
+// openBlob opens a blob (or a byte range of it) for streaming. Retries and
+// the circuit breaker only cover the connection phase; the body is read by
+// the caller.
+func (h *Handler) openBlob(ctx context.Context, path string, offset, count int64) (io.ReadCloser, *storage.BlobInfo, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "blob.open", trace.WithAttributes(
+		attribute.String("blob.path", path),
+		attribute.Int64("blob.offset", offset),
+		attribute.Int64("blob.count", count),
+	))
+	defer span.End()
+	res, cancel, err := h.withRetry(ctx, "open", true, func(ctx context.Context) (interface{}, error) {
+		body, info, err := h.store.GetRange(ctx, path, offset, count)
+		if err != nil {
+			return nil, err
+		}
+		return &openedBlob{ReadCloser: body, info: info}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	ob, ok := res.(*openedBlob)
+	if !ok {
+		cancel()
+		return nil, nil, fmt.Errorf("unexpected breaker result")
+	}
+	return &cancelOnClose{ReadCloser: ob.ReadCloser, cancel: cancel}, ob.info, nil
+}
+
+// statBlob fetches blob properties with the same retry and breaker policy.
+func (h *Handler) statBlob(ctx context.Context, path string) (*storage.BlobInfo, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "blob.stat", trace.WithAttributes(attribute.String("blob.path", path)))
+	defer span.End()
+	res, _, err := h.withRetry(ctx, "stat", false, func(ctx context.Context) (interface{}, error) {
+		return h.store.Stat(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	info, ok := res.(*storage.BlobInfo)
+	if !ok {
+		return nil, fmt.Errorf("unexpected breaker result")
+	}
+	return info, nil
+}
+
+type openedBlob struct {
+	io.ReadCloser
+	info *storage.BlobInfo
+}
+
+// cancelOnClose releases the attempt context once the body is done.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnClose) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
+
+// streamBlob serves a blob from the cache, from a fetch shared with
+// concurrent requests, or streams it straight from the blob store. HEAD and
+// single-range requests are answered without transferring the whole object.
+// Caching headers are set by the caller.
+func (h *Handler) streamBlob(c *gin.Context, kind, uploadID, blobPath, contentType string) {
+	cacheKey := cache.GenerateKey(kind, uploadID, blobPath)
+	c.Header("Accept-Ranges", "bytes")
+
+	if c.Request.Method == http.MethodHead {
+		h.headBlob(c, kind, cacheKey, blobPath, contentType)
+		return
+	}
+	if hdr := c.GetHeader("Range"); hdr != "" {
+		r, err := parseRange(hdr)
+		switch {
+		case err == nil:
+			h.serveRange(c, kind, cacheKey, blobPath, contentType, r)
+			return
+		case errors.Is(err, errMalformedRange):
+			// Ignore and serve the whole object.
+		default:
+			c.String(http.StatusRequestedRangeNotSatisfiable, err.Error())
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	entry, err := h.cache.Get(ctx, cacheKey)
+	if err != nil {
+		h.log.Warnw("cache get error", "err", err)
+	}
+	metrics.CacheLookup(kind, entry != nil)
+	if entry != nil {
+		if notModified(c, entry.Meta.ETag, entry.Meta.LastModified) {
+			return
+		}
+		c.Data(http.StatusOK, contentType, entry.Data)
+		return
+	}
+
+	// Small objects are fetched once per pod no matter how many viewers
+	// miss the cache at the same time; large ones are streamed per request.
+	body, info, err := h.openShared(ctx, uploadID, blobPath, cacheKey)
+	if errors.Is(err, errTooLarge) {
+		body, info, err = h.openBlob(ctx, blobPath, 0, 0)
+	}
+	if err != nil {
+		h.log.Errorw(kind+" download", "path", blobPath, "err", err)
+		blobError(c, err)
+		return
+	}
+	defer body.Close()
+	if notModified(c, info.ETag, info.LastModified) {
+		return
+	}
+
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+	if n, err := io.Copy(c.Writer, body); err != nil {
+		// Headers are already sent; all we can do is cut the response short.
+		h.log.Warnw(kind+" stream aborted", "path", blobPath, "written", n, "err", err)
+	}
+}
+
+// headBlob answers a HEAD request from the cached length or blob properties.
+func (h *Handler) headBlob(c *gin.Context, kind, cacheKey, blobPath, contentType string) {
+	ctx := c.Request.Context()
+	size, meta, err := h.cache.Stat(ctx, cacheKey)
+	if err != nil {
+		h.log.Warnw("cache stat error", "err", err)
+	}
+	if size == 0 {
+		info, err := h.statBlob(ctx, blobPath)
+		if err != nil {
+			h.log.Errorw(kind+" stat", "path", blobPath, "err", err)
+			blobError(c, err)
+			return
+		}
+		size = info.Size
+		meta = cache.Meta{ETag: info.ETag, LastModified: info.LastModified}
+	}
+	if notModified(c, meta.ETag, meta.LastModified) {
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.FormatInt(size, 10))
+	c.Status(http.StatusOK)
+}
+
+// serveRange answers a single-range request with 206 Partial Content, from
+// the cache when the object is there and with a ranged blob read otherwise.
+func (h *Handler) serveRange(c *gin.Context, kind, cacheKey, blobPath, contentType string, r byteRange) {
+	ctx := c.Request.Context()
+	from, to := r.start, r.end
+	if r.suffix > 0 {
+		from = -r.suffix
+	}
+	entry, size, err := h.cache.GetRange(ctx, cacheKey, from, to)
+	if err != nil {
+		h.log.Warnw("cache get range error", "err", err)
+	}
+	if size > 0 {
+		if notModified(c, entry.Meta.ETag, entry.Meta.LastModified) {
+			return
+		}
+		start, end, err := r.resolve(size)
+		if err != nil {
+			unsatisfiable(c, size)
+			return
+		}
+		if int64(len(entry.Data)) == end-start+1 {
+			c.Header("Content-Range", contentRange(start, end, size))
+			c.Data(http.StatusPartialContent, contentType, entry.Data)
+			return
+		}
+	}
+
+	// Suffix ranges need the object size up front.
+	offset, count := r.start, int64(0)
+	if r.suffix > 0 {
+		info, err := h.statBlob(ctx, blobPath)
+		if err != nil {
+			h.log.Errorw(kind+" stat", "path", blobPath, "err", err)
+			blobError(c, err)
+			return
+		}
+		start, _, err := r.resolve(info.Size)
+		if err != nil {
+			unsatisfiable(c, info.Size)
+			return
+		}
+		offset = start
+	} else if r.end >= 0 {
+		count = r.end - r.start + 1
+	}
+
+	body, info, err := h.openBlob(ctx, blobPath, offset, count)
+	if errors.Is(err, storage.ErrInvalidRange) {
+		var size int64
+		if info, err := h.statBlob(ctx, blobPath); err == nil {
+			size = info.Size
+		}
+		unsatisfiable(c, size)
+		return
+	}
+	if err != nil {
+		h.log.Errorw(kind+" download", "path", blobPath, "err", err)
+		blobError(c, err)
+		return
+	}
+	defer body.Close()
+	if notModified(c, info.ETag, info.LastModified) {
+		return
+	}
+
+	start, end, err := r.resolve(info.Size)
+	if err != nil {
+		unsatisfiable(c, info.Size)
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Range", contentRange(start, end, info.Size))
+	c.Header("Content-Length", strconv.FormatInt(end-start+1, 10))
+	c.Status(http.StatusPartialContent)
+	if n, err := io.Copy(c.Writer, body); err != nil {
+		h.log.Warnw(kind+" stream aborted", "path", blobPath, "written", n, "err", err)
+	}
+}
+
+func unsatisfiable(c *gin.Context, size int64) {
+	if size > 0 {
+		c.Header("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+	}
+	c.String(http.StatusRequestedRangeNotSatisfiable, "range not satisfiable")
+}
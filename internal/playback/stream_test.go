@@ -0,0 +1,40 @@
+package playback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/sony/gobreaker"
+
+	"github.com/streamhive/playback-service/internal/storage"
+)
+
+func TestInvalidRangeKeepsBreakerClosed(t *testing.T) {
+	th := newTestHandler(t)
+
+	// More than blob.breaker_failures reads past the end of the segment.
+	for i := 0; i < 10; i++ {
+		_, _, err := th.h.openBlob(context.Background(), testSegment, 100, 0)
+		if !errors.Is(err, storage.ErrInvalidRange) {
+			t.Fatalf("open %d: err = %v, want ErrInvalidRange", i, err)
+		}
+	}
+	if st := th.h.breaker.State(); st != gobreaker.StateClosed {
+		t.Fatalf("breaker %s after invalid ranges", st)
+	}
+
+	const url = "/playback/videos/u1/720p/seg0.ts"
+	w := th.do(http.MethodGet, url, http.Header{"Range": {"bytes=100-"}})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("range: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */10" {
+		t.Errorf("Content-Range = %q", got)
+	}
+	w = th.do(http.MethodGet, url, nil)
+	if w.Code != http.StatusOK || w.Body.String() != "0123456789" {
+		t.Fatalf("normal request: %d %q", w.Code, w.Body.String())
+	}
+}
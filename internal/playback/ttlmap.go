@@ -0,0 +1,66 @@
+package playback
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlMap is a small concurrency-safe map whose entries expire.
+type ttlMap[V any] struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]ttlEntry[V]
+	lastSweep time.Time
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLMap[V any](ttl time.Duration) *ttlMap[V] {
+	return &ttlMap[V]{ttl: ttl, entries: make(map[string]ttlEntry[V])}
+}
+
+func (m *ttlMap[V]) Get(key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		delete(m.entries, key)
+		var zero V
+		return zero, false
+	}
+	return e.value, true
+}
+
+func (m *ttlMap[V]) Set(key string, v V) {
+	m.SetTTL(key, v, m.ttl)
+}
+
+// SetTTL stores v with its own lifetime, capped at the map's TTL.
+func (m *ttlMap[V]) SetTTL(key string, v V, ttl time.Duration) {
+	if ttl > m.ttl {
+		ttl = m.ttl
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	// Drop expired entries once per TTL so the map stays bounded by the
+	// working set.
+	if now.Sub(m.lastSweep) > m.ttl {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	m.entries[key] = ttlEntry[V]{value: v, expires: now.Add(ttl)}
+}
+
+func (m *ttlMap[V]) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
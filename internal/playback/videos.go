@@ -0,0 +1,105 @@
+package playback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/streamhive/playback-service/internal/cache"
+	"github.com/streamhive/playback-service/internal/metrics"
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// cachedVideo is the shared cache representation of a lookup. A nil Video
+// records that the upload does not exist. Expires bounds the entry's life
+// independently of the cache backend's TTL.
+type cachedVideo struct {
+	Video   *models.Video `json:"video"`
+	Expires time.Time     `json:"expires"`
+}
+
+// Video rows change rarely but are needed by every request, so they are
+// cached briefly in-process and in the shared cache. Unknown upload IDs are
+// remembered for a shorter time so a missing video does not stay missing
+// long after it is published. Updates are picked up via purge.
+//
+// videoCache is the in-process tier; nil values are known-missing uploads.
+type videoCache struct {
+	entries     *ttlMap[*models.Video]
+	ttl         time.Duration
+	negativeTTL time.Duration
+}
+
+func newVideoCache(ttl, negativeTTL time.Duration) *videoCache {
+	return &videoCache{
+		entries:     newTTLMap[*models.Video](ttl),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// findVideo loads the video row for an upload ID, from the in-process tier,
+// the shared cache or Postgres in that order. Concurrent misses for the same
+// upload share one query. Unknown IDs yield gorm.ErrRecordNotFound.
+func (h *Handler) findVideo(ctx context.Context, uploadID string, v *models.Video) error {
+	found, ok := h.videos.entries.Get(uploadID)
+	metrics.CacheLookup("video", ok)
+	if !ok {
+		res, err := h.shared(ctx, "video:"+uploadID, func(ctx context.Context) (interface{}, error) {
+			return h.loadVideo(ctx, uploadID)
+		})
+		if err != nil {
+			return err
+		}
+		found = res.(*models.Video)
+	}
+	if found == nil {
+		return gorm.ErrRecordNotFound
+	}
+	*v = *found
+	return nil
+}
+
+func (h *Handler) loadVideo(ctx context.Context, uploadID string) (*models.Video, error) {
+	key := videoCacheKey(uploadID)
+	if entry, err := h.cache.Get(ctx, key); err == nil && entry != nil {
+		var cv cachedVideo
+		if err := json.Unmarshal(entry.Data, &cv); err == nil {
+			if ttl := time.Until(cv.Expires); ttl > 0 {
+				h.videos.entries.SetTTL(uploadID, cv.Video, ttl)
+				return cv.Video, nil
+			}
+		}
+	}
+
+	var v models.Video
+	err := h.db.WithContext(ctx).Where("upload_id = ?", uploadID).First(&v).Error
+	found := &v
+	ttl := h.videos.ttl
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		found, ttl = nil, h.videos.negativeTTL
+	case err != nil:
+		return nil, err
+	}
+	h.videos.entries.SetTTL(uploadID, found, ttl)
+	if data, err := json.Marshal(cachedVideo{Video: found, Expires: time.Now().Add(ttl)}); err == nil {
+		if err := h.cache.Set(ctx, key, data, cache.Meta{UploadID: uploadID}); err != nil {
+			h.log.Debugw("video cache set", "uploadId", uploadID, "err", err)
+		}
+	}
+	return found, nil
+}
+
+// forgetVideo drops this pod's copy of a video row; the shared copy goes
+// with the upload's other keys in cache.Purge.
+func (h *Handler) forgetVideo(uploadID string) {
+	h.videos.entries.Delete(uploadID)
+}
+
+func videoCacheKey(uploadID string) string {
+	return cache.GenerateKey("video", uploadID, "")
+}
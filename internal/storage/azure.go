@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+)
+
+// AzureStore reads blobs from a single Azure Blob Storage container.
+type AzureStore struct {
+	client *container.Client
+}
+
+// NewAzureStore creates a container client from a connection string,
+// a shared key, or anonymously when only the account is known.
+func NewAzureStore(cfg Config) (*AzureStore, error) {
+	if cfg.AzureContainer == "" {
+		return nil, errors.New("azure container name is not configured")
+	}
+	if cfg.AzureConnectionString != "" {
+		c, err := container.NewClientFromConnectionString(cfg.AzureConnectionString, cfg.AzureContainer, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &AzureStore{client: c}, nil
+	}
+	if cfg.AzureAccount == "" {
+		return nil, errors.New("azure storage account is not configured")
+	}
+	url := "https://" + cfg.AzureAccount + ".blob.core.windows.net/" + cfg.AzureContainer
+	if cfg.AzureKey == "" {
+		c, err := container.NewClientWithNoCredential(url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &AzureStore{client: c}, nil
+	}
+	cred, err := azblob.NewSharedKeyCredential(cfg.AzureAccount, cfg.AzureKey)
+	if err != nil {
+		return nil, err
+	}
+	c, err := container.NewClientWithSharedKeyCredential(url, cred, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &AzureStore{client: c}, nil
+}
+
+// Ping reads the container properties. With anonymous access this only
+// works for containers with container-level public access.
+func (s *AzureStore) Ping(ctx context.Context) error {
+	_, err := s.client.GetProperties(ctx, nil)
+	return azureErr(err)
+}
+
+func (s *AzureStore) Get(ctx context.Context, path string) (io.ReadCloser, *BlobInfo, error) {
+	return s.GetRange(ctx, path, 0, 0)
+}
+
+func (s *AzureStore) GetRange(ctx context.Context, path string, offset, count int64) (io.ReadCloser, *BlobInfo, error) {
+	opts := &blob.DownloadStreamOptions{Range: blob.HTTPRange{Offset: offset, Count: count}}
+	resp, err := s.client.NewBlobClient(path).DownloadStream(ctx, opts)
+	if err != nil {
+		return nil, nil, azureErr(err)
+	}
+	info := &BlobInfo{Path: path}
+	if resp.ContentLength != nil {
+		info.Size = *resp.ContentLength
+	}
+	if resp.ContentRange != nil {
+		// "bytes start-end/total"
+		if total, ok := totalFromContentRange(*resp.ContentRange); ok {
+			info.Size = total
+		}
+	}
+	if resp.ContentType != nil {
+		info.ContentType = *resp.ContentType
+	}
+	if resp.ETag != nil {
+		info.ETag = string(*resp.ETag)
+	}
+	if resp.LastModified != nil {
+		info.LastModified = *resp.LastModified
+	}
+	return resp.Body, info, nil
+}
+
+func (s *AzureStore) Stat(ctx context.Context, path string) (*BlobInfo, error) {
+	props, err := s.client.NewBlobClient(path).GetProperties(ctx, nil)
+	if err != nil {
+		return nil, azureErr(err)
+	}
+	info := &BlobInfo{Path: path}
+	if props.ContentLength != nil {
+		info.Size = *props.ContentLength
+	}
+	if props.ContentType != nil {
+		info.ContentType = *props.ContentType
+	}
+	if props.ETag != nil {
+		info.ETag = string(*props.ETag)
+	}
+	if props.LastModified != nil {
+		info.LastModified = *props.LastModified
+	}
+	return info, nil
+}
+
+func (s *AzureStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	var out []BlobInfo
+	pager := s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{Prefix: &prefix})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, azureErr(err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			info := BlobInfo{Path: *item.Name}
+			if p := item.Properties; p != nil {
+				if p.ContentLength != nil {
+					info.Size = *p.ContentLength
+				}
+				if p.ContentType != nil {
+					info.ContentType = *p.ContentType
+				}
+				if p.ETag != nil {
+					info.ETag = string(*p.ETag)
+				}
+				if p.LastModified != nil {
+					info.LastModified = *p.LastModified
+				}
+			}
+			out = append(out, info)
+		}
+	}
+	return out, nil
+}
+
+// azureErr maps well-known service errors onto the storage sentinels.
+func azureErr(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound) {
+		return ErrNotFound
+	}
+	if bloberror.HasCode(err, bloberror.InvalidRange) {
+		return ErrInvalidRange
+	}
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.StatusCode {
+		case 404:
+			return ErrNotFound
+		case 416:
+			return ErrInvalidRange
+		}
+	}
+	return err
+}
+
+func totalFromContentRange(v string) (int64, bool) {
+	i := strings.LastIndexByte(v, '/')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
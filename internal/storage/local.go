@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore serves blobs from a directory laid out like the container,
+// e.g. a folder of transcoder HLS output on a laptop.
+type LocalStore struct {
+	root string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, errors.New("local blob directory is not configured")
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	st, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, errors.New(abs + " is not a directory")
+	}
+	return &LocalStore{root: abs}, nil
+}
+
+// resolve maps a blob path into the root, refusing to escape it.
+func (s *LocalStore) resolve(p string) string {
+	return filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+p)))
+}
+
+// Ping checks that the root directory still exists.
+func (s *LocalStore) Ping(ctx context.Context) error {
+	st, err := os.Stat(s.root)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.root)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(ctx context.Context, p string) (io.ReadCloser, *BlobInfo, error) {
+	return s.GetRange(ctx, p, 0, 0)
+}
+
+func (s *LocalStore) GetRange(ctx context.Context, p string, offset, count int64) (io.ReadCloser, *BlobInfo, error) {
+	f, err := os.Open(s.resolve(p))
+	if err != nil {
+		return nil, nil, localErr(err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	if st.IsDir() {
+		f.Close()
+		return nil, nil, ErrNotFound
+	}
+	if offset > 0 && offset >= st.Size() {
+		f.Close()
+		return nil, nil, ErrInvalidRange
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	info := fileInfo(p, st)
+	if count <= 0 {
+		return f, info, nil
+	}
+	return &limitedFile{Reader: io.LimitReader(f, count), Closer: f}, info, nil
+}
+
+func (s *LocalStore) Stat(ctx context.Context, p string) (*BlobInfo, error) {
+	st, err := os.Stat(s.resolve(p))
+	if err != nil {
+		return nil, localErr(err)
+	}
+	if st.IsDir() {
+		return nil, ErrNotFound
+	}
+	return fileInfo(p, st), nil
+}
+
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	var out []BlobInfo
+	err := filepath.WalkDir(s.root, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, fp)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !strings.HasPrefix(rel, prefix) {
+			return nil
+		}
+		st, err := d.Info()
+		if err != nil {
+			return err
+		}
+		out = append(out, *fileInfo(rel, st))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type limitedFile struct {
+	io.Reader
+	io.Closer
+}
+
+func fileInfo(p string, st os.FileInfo) *BlobInfo {
+	return &BlobInfo{
+		Path:         p,
+		Size:         st.Size(),
+		ContentType:  mime.TypeByExtension(path.Ext(p)),
+		LastModified: st.ModTime().UTC(),
+		// Same scheme as nginx: modification time and size.
+		ETag: fmt.Sprintf(`"%x-%x"`, st.ModTime().Unix(), st.Size()),
+	}
+}
+
+func localErr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
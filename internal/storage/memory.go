@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps blobs in a map; intended for tests and local runs.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memBlob
+}
+
+type memBlob struct {
+	data []byte
+	info BlobInfo
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{blobs: make(map[string]memBlob)}
+}
+
+// Put stores (or replaces) a blob.
+func (s *MemoryStore) Put(p string, data []byte, contentType string) {
+	sum := sha256.Sum256(data)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[p] = memBlob{
+		data: append([]byte(nil), data...),
+		info: BlobInfo{
+			Path:         p,
+			Size:         int64(len(data)),
+			ContentType:  contentType,
+			ETag:         fmt.Sprintf(`"%x"`, sum[:16]),
+			LastModified: time.Now().UTC(),
+		},
+	}
+}
+
+func (s *MemoryStore) Ping(ctx context.Context) error { return nil }
+
+func (s *MemoryStore) Get(ctx context.Context, p string) (io.ReadCloser, *BlobInfo, error) {
+	return s.GetRange(ctx, p, 0, 0)
+}
+
+func (s *MemoryStore) GetRange(ctx context.Context, p string, offset, count int64) (io.ReadCloser, *BlobInfo, error) {
+	s.mu.RLock()
+	b, ok := s.blobs[p]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+	data := b.data
+	if offset > 0 && offset >= int64(len(data)) {
+		return nil, nil, ErrInvalidRange
+	}
+	data = data[offset:]
+	if count > 0 && count < int64(len(data)) {
+		data = data[:count]
+	}
+	info := b.info
+	return io.NopCloser(bytes.NewReader(data)), &info, nil
+}
+
+func (s *MemoryStore) Stat(ctx context.Context, p string) (*BlobInfo, error) {
+	s.mu.RLock()
+	b, ok := s.blobs[p]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrNotFound
+	}
+	info := b.info
+	return &info, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]BlobInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []BlobInfo
+	for p, b := range s.blobs {
+		if strings.HasPrefix(p, prefix) {
+			out = append(out, b.info)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned when a blob does not exist in the store.
+	ErrNotFound = errors.New("blob not found")
+	// ErrInvalidRange is returned when a ranged read starts past the end of the blob.
+	ErrInvalidRange = errors.New("invalid blob range")
+)
+
+// BlobInfo describes a stored object.
+type BlobInfo struct {
+	Path         string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// BlobStore is the read-only view of HLS output the playback service needs.
+// Paths are relative to the store root (container or directory) and use '/'.
+type BlobStore interface {
+	// Get opens the whole blob for reading.
+	Get(ctx context.Context, path string) (io.ReadCloser, *BlobInfo, error)
+	// GetRange opens count bytes starting at offset; count <= 0 reads to the end.
+	// The returned BlobInfo.Size is always the size of the whole blob.
+	GetRange(ctx context.Context, path string, offset, count int64) (io.ReadCloser, *BlobInfo, error)
+	// Stat returns blob properties without reading the content.
+	Stat(ctx context.Context, path string) (*BlobInfo, error)
+	// List returns all blobs whose path starts with prefix.
+	List(ctx context.Context, prefix string) ([]BlobInfo, error)
+	// Ping checks that the backing container is reachable.
+	Ping(ctx context.Context) error
+}
+
+// Config selects and configures a BlobStore backend.
+type Config struct {
+	Backend string // azure (default), local or memory
+	// LocalDir is the root directory of the local backend.
+	LocalDir string
+	// Azure settings; a connection string wins over account+key, and an
+	// account without a key falls back to anonymous (public container) access.
+	AzureAccount          string
+	AzureContainer        string
+	AzureConnectionString string
+	AzureKey              string
+}
+
+// New builds the BlobStore selected by cfg.Backend.
+func New(cfg Config) (BlobStore, error) {
+	switch cfg.Backend {
+	case "", "azure":
+		return NewAzureStore(cfg)
+	case "local":
+		return NewLocalStore(cfg.LocalDir)
+	case "memory":
+		return NewMemoryStore(), nil
+	}
+	return nil, fmt.Errorf("unknown blob backend %q", cfg.Backend)
+}
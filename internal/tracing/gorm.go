@@ -0,0 +1,53 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// InstrumentDB adds a client span around every gorm query. Queries must be
+// issued with WithContext for the spans to join the request trace.
+func InstrumentDB(db *gorm.DB) error {
+	const spanKey = "tracing:span"
+	before := func(tx *gorm.DB) {
+		_, span := Tracer().Start(tx.Statement.Context, "db.query", trace.WithSpanKind(trace.SpanKindClient))
+		tx.InstanceSet(spanKey, span)
+	}
+	after := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+		span, ok := v.(trace.Span)
+		if !ok {
+			return
+		}
+		defer span.End()
+		span.SetAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBCollectionName(tx.Statement.Table),
+			semconv.DBQueryText(tx.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+		)
+		if err := tx.Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tracing:before_query", before); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("tracing:after_query", after); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tracing:before_row", before); err != nil {
+		return err
+	}
+	return cb.Row().After("gorm:row").Register("tracing:after_row", after)
+}
@@ -0,0 +1,78 @@
+// Package tracing sets up OpenTelemetry tracing: the exporter, W3C trace
+// context propagation, a gin middleware and gorm instrumentation.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const instrumentation = "github.com/streamhive/playback-service"
+
+// Tracer returns the service's tracer. Spans are no-ops until Init installs
+// a provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Init installs the tracer provider selected by OTEL_TRACES_EXPORTER:
+// "otlp" (OTLP/HTTP, configured through the standard OTEL_EXPORTER_OTLP_*
+// variables), "console" or "stdout" for local debugging, or "none". When it
+// is unset, OTLP is used if OTEL_EXPORTER_OTLP_ENDPOINT is set. Sampling
+// follows OTEL_TRACES_SAMPLER. The returned func flushes pending spans.
+func Init(ctx context.Context, log *zap.SugaredLogger) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	exporter := os.Getenv("OTEL_TRACES_EXPORTER")
+	if exporter == "" {
+		exporter = "none"
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != "" {
+			exporter = "otlp"
+		}
+	}
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "none":
+		log.Infow("tracing disabled")
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exp, err = otlptracehttp.New(ctx)
+	case "console", "stdout":
+		exp, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q", exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trace exporter %s: %w", exporter, err)
+	}
+
+	name := os.Getenv("OTEL_SERVICE_NAME")
+	if name == "" {
+		name = "playback-service"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(name)))
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	log.Infow("tracing enabled", "exporter", exporter, "service", name)
+	return tp.Shutdown, nil
+}